@@ -1,12 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"shared/apperr"
+	sharedconfig "shared/config"
+	sharedmw "shared/middlewares"
+	"shared/ratelimit"
 )
 
 type Card struct {
@@ -32,6 +48,22 @@ var values = map[string]int{
 var deck []Card
 var players []Player
 
+// 游戏指标，通过 METRICS_ADDR 上的 /metrics 暴露
+var (
+	gamesPlayed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bullfight_games_total",
+		Help: "Total number of games played.",
+	})
+	handsDealt = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bullfight_hands_dealt_total",
+		Help: "Total number of hands dealt, including the dealer's.",
+	})
+	handTypes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bullfight_hand_types_total",
+		Help: "Total number of hands dealt by hand type.",
+	}, []string{"hand_type"})
+)
+
 // 初始化牌堆
 func initDeck() {
 	for _, suit := range suits {
@@ -232,7 +264,10 @@ func gameLogic() {
 		handType, multiple := checkHand(players[i].Cards)
 		players[i].HandType = handType
 		players[i].Multiple = multiple
+		handTypes.WithLabelValues(handType).Inc()
 	}
+	gamesPlayed.Inc()
+	handsDealt.Add(float64(len(players)))
 
 	// 输出每个玩家的牌型和得分
 	for _, player := range players {
@@ -260,10 +295,129 @@ func startGame(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"game_results": results})
 }
 
+// 构建信息，发布时通过 ldflags 注入：
+// go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+// 存活检查，不检查外部依赖
+func liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// 返回构建信息
+func versionInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"version":    version,
+		"commit":     commit,
+		"build_time": buildTime,
+		"go_version": runtime.Version(),
+	})
+}
+
+// ready 是否接收新流量，收到退出信号后置为 false
+var ready atomic.Bool
+
+// 就绪检查，退出过程中返回 503，负载均衡据此摘除实例
+func readiness(c *gin.Context) {
+	if !ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// serverConfig 服务配置，由 shared/config.Load 从 default 标签、CONFIG_FILE 和环境变量加载
+type serverConfig struct {
+	Port              int               `yaml:"port" env:"PORT" default:"8080"`
+	MetricsAddr       string            `yaml:"metrics_addr" env:"METRICS_ADDR" default:":9090"`                         // /metrics 的监听地址，不要通过负载均衡对外暴露
+	ShutdownDelay     time.Duration     `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"5s"`                        // 收到退出信号后先标记为未就绪，等待负载均衡摘除实例
+	ShutdownTimeout   time.Duration     `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`                   // 等待进行中的请求完成的最长时间
+	TrustedProxies    []string          `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`                                   // 可信代理的 IP 或 CIDR，只采信它们转发的 X-Forwarded-For
+	RateLimitStart    sharedconfig.Rate `yaml:"rate_limit_start" env:"RATE_LIMIT_START" default:"30/m"`                  // 每个 IP 开始游戏的频率，0 表示不限流
+	RateLimitStore    string            `yaml:"rate_limit_store" env:"RATE_LIMIT_STORE" default:"memory"`                // memory 或 redis，多副本部署时使用 redis，各实例共享计数
+	RateLimitRedisURL string            `yaml:"rate_limit_redis_url" env:"RATE_LIMIT_REDIS_URL,REDIS_URL" secret:"true"` // 例如 redis://:password@localhost:6379/0
+}
+
 func main() {
+	var cfg serverConfig
+	if err := sharedconfig.Load(&cfg); err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
 	r := gin.Default()
+	// 只采信可信代理转发的客户端地址，否则 X-Forwarded-For 可以伪造，绕过按 IP 的限流
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// 限流超过限制时由 ErrorHandler 输出 429，提示信息使用中文
+	apperr.SetMessages(apperr.Messages{
+		InvalidInput:    "请求参数不合法",
+		FieldRule:       "不满足 %s 规则",
+		FieldType:       "类型应为 %s",
+		RequestTooLarge: "请求体过大",
+		RateLimited:     "请求过于频繁，请稍后再试",
+		Internal:        "服务器内部错误",
+	})
+	r.Use(sharedmw.ErrorHandler())
+
+	// 限制每个 IP 开始游戏的频率，RATE_LIMIT_START=0 时不限流
+	limiter, err := ratelimit.New(ratelimit.Config{Driver: cfg.RateLimitStore, RedisURL: cfg.RateLimitRedisURL})
+	if err != nil {
+		log.Fatalf("限流存储初始化失败: %v", err)
+	}
+	defer limiter.Close()
+	startLimit := ratelimit.Limit{Burst: cfg.RateLimitStart.Limit, Period: cfg.RateLimitStart.Period}
+
+	r.GET("/start", sharedmw.RateLimit(limiter, "start", startLimit, sharedmw.ClientIPKey), startGame)
+	r.GET("/healthz", liveness)
+	r.GET("/readyz", readiness)
+	r.GET("/version", versionInfo)
 
-	r.GET("/start", startGame)
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务启动失败: %v", err)
+		}
+	}()
 
-	r.Run(":8080")
+	// Prometheus 指标在单独的端口上提供，不挂在业务路由上，不要通过负载均衡对外暴露
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsSrv := &http.Server{
+		Addr:              cfg.MetricsAddr,
+		Handler:           metricsMux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("指标服务启动失败: %v", err)
+		}
+	}()
+	ready.Store(true)
+
+	// 收到 SIGTERM 后先标记为未就绪，等待负载均衡摘除实例，再等待进行中的请求完成
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	log.Println("收到退出信号，开始停止服务")
+	ready.Store(false)
+	time.Sleep(cfg.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("%s 内未能处理完进行中的请求: %v", cfg.ShutdownTimeout, err)
+		srv.Close()
+	}
+	metricsSrv.Close()
+	log.Println("服务已停止")
 }
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveKey 使用 HKDF-SHA256 从密钥派生指定用途的 32 字节子密钥，不同用途的子密钥互不相关
+func DeriveKey(secret, purpose string) []byte {
+	key := make([]byte, 32)
+	r := hkdf.New(sha256.New, []byte(secret), nil, []byte("go_core "+purpose))
+	if _, err := io.ReadFull(r, key); err != nil {
+		// 32 字节远小于 HKDF-SHA256 的输出上限，不会出错
+		panic(err)
+	}
+	return key
+}
+
+// deriveSecret 未单独配置的密钥由 AUTH_SECRET_KEY 派生，主密钥为空时保持原值
+func deriveSecret(current *string, master, purpose string) {
+	if *current == "" && master != "" {
+		*current = hex.EncodeToString(DeriveKey(master, purpose))
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// setTestEnv 设置加载配置所需的最少环境变量
+func setTestEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_NAME", "test.db")
+	t.Setenv("SMTP_HOST", "localhost")
+	t.Setenv("JWT_SECRET", "jwt-secret")
+}
+
+func TestLoadDerivesSecrets(t *testing.T) {
+	setTestEnv(t)
+	t.Setenv("AUTH_SECRET_KEY", "master-secret")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	derived := []string{cfg.Auth.CursorSecret, cfg.Auth.EmailTokenSecret, cfg.Auth.MFASecretKey}
+	seen := map[string]bool{}
+	for _, secret := range derived {
+		if secret == "" || secret == "master-secret" || secret == "jwt-secret" || seen[secret] {
+			t.Fatalf("derived secrets are not independent: %q", derived)
+		}
+		seen[secret] = true
+	}
+
+	// 派生结果固定，多副本使用相同的主密钥即可
+	again, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Auth.MFASecretKey != cfg.Auth.MFASecretKey {
+		t.Fatal("derived secret changed between loads")
+	}
+
+	// 单独配置的密钥优先
+	t.Setenv("CURSOR_SECRET", "cursor-secret")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.CursorSecret != "cursor-secret" {
+		t.Fatalf("CursorSecret = %q, want the configured value", cfg.Auth.CursorSecret)
+	}
+}
+
+func TestValidateRejectsJWTSecretFallback(t *testing.T) {
+	setTestEnv(t)
+
+	t.Setenv("AUTH_SECRET_KEY", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "AUTH_MFA_SECRET_KEY is required") {
+		t.Fatalf("Load without AUTH_SECRET_KEY error = %v", err)
+	}
+
+	t.Setenv("AUTH_SECRET_KEY", "jwt-secret")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "AUTH_SECRET_KEY must differ from JWT_SECRET") {
+		t.Fatalf("Load with AUTH_SECRET_KEY = JWT_SECRET error = %v", err)
+	}
+
+	t.Setenv("AUTH_SECRET_KEY", "master-secret")
+	t.Setenv("AUTH_MFA_SECRET_KEY", "jwt-secret")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "AUTH_MFA_SECRET_KEY must differ from JWT_SECRET") {
+		t.Fatalf("Load with AUTH_MFA_SECRET_KEY = JWT_SECRET error = %v", err)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	a := DeriveKey("secret", "cursor")
+	if len(a) != 32 {
+		t.Fatalf("key is %d bytes, want 32", len(a))
+	}
+	if string(a) == string(DeriveKey("secret", "email token")) || string(a) == string(DeriveKey("other", "cursor")) {
+		t.Fatal("keys for different purposes or secrets are equal")
+	}
+	if string(a) != string(DeriveKey("secret", "cursor")) {
+		t.Fatal("DeriveKey is not deterministic")
+	}
+}
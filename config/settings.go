@@ -0,0 +1,390 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	sharedconfig "shared/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 应用的全部配置，由 shared/config.Load 按标签加载
+// 加载顺序（后者覆盖前者）：default 标签 -> CONFIG_FILE 指定的 YAML 文件 -> .env 和环境变量
+// env 标签可以列出多个变量名，按顺序取第一个有值的；secret 标签的字段在日志中会被隐藏
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Database  DatabaseConfig  `yaml:"database"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Lockout   LockoutConfig   `yaml:"lockout"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Mail      MailConfig      `yaml:"mail"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Upload    UploadConfig    `yaml:"upload"`
+	Image     ImageConfig     `yaml:"image"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Env               string        `yaml:"env" env:"APP_ENV" default:"production"` // 运行环境，只有 development 允许使用空的、占位的或过短的密钥
+	Port              int           `yaml:"port" env:"PORT" default:"8080"`
+	MetricsAddr       string        `yaml:"metrics_addr" env:"METRICS_ADDR" default:":9090"` // /metrics 的监听地址，与业务端口分开，不要通过负载均衡对外暴露；配置文件中设为空时不提供指标
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" default:"10s"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"5s"`      // 收到退出信号后先标记为未就绪，等待负载均衡摘除实例
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"` // 等待进行中的请求完成的最长时间
+	TrustedProxies    []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`                 // 可信代理的 IP 或 CIDR，只有来自这些地址的 X-Forwarded-For 才被采信，为空时使用连接的对端地址
+}
+
+// Addr 监听地址
+func (c ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" default:"info"`   // debug、info、warn 或 error，debug 时记录所有 SQL
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json"` // json 或 text
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置，变量名沿用 OpenTelemetry 的约定
+// 导出器的其他设置（如 OTEL_EXPORTER_OTLP_HEADERS）由 SDK 直接读取环境变量
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none"` // none、stdout 或 otlp，none 时只传播上游的 trace 上下文
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`  // OTLP/HTTP 的完整地址，例如 http://localhost:4318/v1/traces，为空时由 SDK 读取 OTEL_EXPORTER_OTLP_ENDPOINT
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"go_core"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" default:"1"` // 新链路的采样比例，上游已采样的请求始终记录
+}
+
+// DatabaseConfig 数据库连接配置，DB_PASS 为 game_service 使用过的旧变量名
+// sqlite 只使用 Name 作为数据库文件路径，Host、Port、User、Password 被忽略
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver" env:"DB_DRIVER" default:"mysql"` // mysql、postgres 或 sqlite
+	Host     string `yaml:"host" env:"DB_HOST" default:"127.0.0.1"`
+	Port     int    `yaml:"port" env:"DB_PORT"` // 为 0 时使用驱动的默认端口
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD,DB_PASS" secret:"true"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"ssl_mode" env:"DB_SSL_MODE" default:"disable"` // 仅 postgres 使用
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+
+	ConnectRetries int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES" default:"10"` // 启动时连接失败的重试次数
+	ConnectBackoff time.Duration `yaml:"connect_backoff" env:"DB_CONNECT_BACKOFF" default:"1s"` // 首次重试的等待时间，之后每次翻倍
+
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" default:"true"` // 启动时执行未执行的迁移，关闭后需先运行 migrate up
+
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" default:"200ms"` // 超过该时间的查询记为 warn，0 表示不记录
+}
+
+// 各驱动的默认端口
+var defaultDatabasePorts = map[string]int{
+	"mysql":    3306,
+	"postgres": 5432,
+}
+
+// JWTConfig Token 签名和有效期配置，密钥的用法见 services.InitJWTKeys
+type JWTConfig struct {
+	SigningAlg      string        `yaml:"signing_alg" env:"JWT_SIGNING_ALG" default:"HS256"`
+	Secret          string        `yaml:"secret" env:"JWT_SECRET" secret:"true"`
+	PrivateKeyFile  string        `yaml:"private_key_file" env:"JWT_PRIVATE_KEY_FILE"`
+	KeyID           string        `yaml:"key_id" env:"JWT_KEY_ID"`
+	VerifyKeys      []string      `yaml:"verify_keys" env:"JWT_VERIFY_KEYS"` // 轮换期间的旧公钥，"kid=path.pem"
+	Issuer          string        `yaml:"issuer" env:"JWT_ISSUER" default:"my-gin-project"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL" default:"15m"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"JWT_REFRESH_TOKEN_TTL" default:"720h"`
+}
+
+// AuthConfig 账号相关配置
+type AuthConfig struct {
+	BcryptCost   int    `yaml:"bcrypt_cost" env:"BCRYPT_COST" default:"12"`
+	AdminEmail   string `yaml:"admin_email" env:"ADMIN_EMAIL"`                   // 启动时授予管理员角色的账号，需已验证邮箱
+	SecretKey    string `yaml:"secret_key" env:"AUTH_SECRET_KEY" secret:"true"`  // 主密钥，下面未单独配置的密钥由它经 HKDF 按用途派生，不能与 JWT_SECRET 相同
+	CursorSecret string `yaml:"cursor_secret" env:"CURSOR_SECRET" secret:"true"` // 分页游标的签名密钥
+
+	RequireVerifiedEmail bool          `yaml:"require_verified_email" env:"AUTH_REQUIRE_VERIFIED_EMAIL" default:"false"` // 邮箱未验证的账号不能登录，开启前注册的账号需要先验证
+	EmailTokenSecret     string        `yaml:"email_token_secret" env:"AUTH_EMAIL_TOKEN_SECRET" secret:"true"`           // 邮箱验证、密码重置和两步验证挑战令牌的签名密钥
+	VerifyEmailTTL       time.Duration `yaml:"verify_email_ttl" env:"AUTH_VERIFY_EMAIL_TTL" default:"48h"`
+	PasswordResetTTL     time.Duration `yaml:"password_reset_ttl" env:"AUTH_PASSWORD_RESET_TTL" default:"1h"`
+
+	MFAIssuer       string        `yaml:"mfa_issuer" env:"AUTH_MFA_ISSUER" default:"go_core"`          // 验证器应用中显示的服务名称
+	MFASecretKey    string        `yaml:"mfa_secret_key" env:"AUTH_MFA_SECRET_KEY" secret:"true"`      // 加密保存 TOTP 密钥和计算恢复码 HMAC 的密钥，更换后已绑定的两步验证全部失效
+	MFAChallengeTTL time.Duration `yaml:"mfa_challenge_ttl" env:"AUTH_MFA_CHALLENGE_TTL" default:"5m"` // 密码验证通过后提交两步验证码的时限
+}
+
+// LockoutConfig 登录失败的锁定策略，账号和 IP 分别计数
+// 失败次数达到阈值后锁定 BaseDuration，之后每多失败一次锁定时间翻倍，最长 MaxDuration
+type LockoutConfig struct {
+	Store           string        `yaml:"store" env:"LOCKOUT_STORE" default:"memory"`                  // memory 或 database，多副本部署时使用 database
+	AccountFailures int           `yaml:"account_failures" env:"LOCKOUT_ACCOUNT_FAILURES" default:"5"` // 同一账号的失败次数阈值，0 表示不按账号锁定
+	IPFailures      int           `yaml:"ip_failures" env:"LOCKOUT_IP_FAILURES" default:"20"`          // 同一 IP 的失败次数阈值，0 表示不按 IP 锁定
+	BaseDuration    time.Duration `yaml:"base_duration" env:"LOCKOUT_BASE_DURATION" default:"1m"`      // 首次锁定的时长
+	MaxDuration     time.Duration `yaml:"max_duration" env:"LOCKOUT_MAX_DURATION" default:"1h"`        // 锁定时长的上限
+	FailureWindow   time.Duration `yaml:"failure_window" env:"LOCKOUT_FAILURE_WINDOW" default:"24h"`   // 超过该时间没有新的失败时重新计数
+}
+
+// RateLimitConfig 接口限流配置，按路由组分别使用令牌桶计数
+// 计数的身份为 ip 或 user（访问令牌中的用户，未登录时按 IP）
+type RateLimitConfig struct {
+	Store    string `yaml:"store" env:"RATE_LIMIT_STORE" default:"memory"`                // memory 或 redis，多副本部署时使用 redis
+	RedisURL string `yaml:"redis_url" env:"RATE_LIMIT_REDIS_URL,REDIS_URL" secret:"true"` // 例如 redis://:password@localhost:6379/0
+
+	Auth     Rate   `yaml:"auth" env:"RATE_LIMIT_AUTH" default:"20/m"` // 登录、注册、刷新令牌、找回密码等公开接口
+	AuthBy   string `yaml:"auth_by" env:"RATE_LIMIT_AUTH_BY" default:"ip"`
+	API      Rate   `yaml:"api" env:"RATE_LIMIT_API" default:"300/m"` // 需要登录的接口
+	APIBy    string `yaml:"api_by" env:"RATE_LIMIT_API_BY" default:"user"`
+	Upload   Rate   `yaml:"upload" env:"RATE_LIMIT_UPLOAD" default:"30/m"` // 上传文件和创建断点续传会话，分块上传只受 API 限制
+	UploadBy string `yaml:"upload_by" env:"RATE_LIMIT_UPLOAD_BY" default:"user"`
+}
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	Driver      string     `yaml:"driver" env:"MAIL_DRIVER" default:"smtp"` // smtp、file 或 log，file 和 log 用于本地开发和测试，log 只能配合 localhost 的 MAIL_LINK_BASE_URL
+	From        string     `yaml:"from" env:"MAIL_FROM" default:"no-reply@localhost"`
+	LinkBaseURL string     `yaml:"link_base_url" env:"MAIL_LINK_BASE_URL" default:"http://localhost:8080"` // 邮件中链接的前缀，通常为前端地址
+	FileDir     string     `yaml:"file_dir" env:"MAIL_FILE_DIR" default:"./data/mail"`                     // file 驱动保存 .eml 文件的目录
+	SMTP        SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig SMTP 服务器配置，端口为 465 时使用 TLS，其他端口在服务器支持时使用 STARTTLS
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" env:"SMTP_PORT" default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// StorageConfig 文件存储配置
+type StorageConfig struct {
+	Driver   string   `yaml:"driver" env:"STORAGE_DRIVER" default:"local"` // local、s3 或 memory
+	LocalDir string   `yaml:"local_dir" env:"STORAGE_LOCAL_DIR" default:"./uploads"`
+	S3       S3Config `yaml:"s3"`
+}
+
+// S3Config S3 兼容存储的连接配置
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"`
+	Region    string `yaml:"region" env:"S3_REGION" default:"us-east-1"`
+	Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" env:"S3_ACCESS_KEY" secret:"true"`
+	SecretKey string `yaml:"secret_key" env:"S3_SECRET_KEY" secret:"true"`
+}
+
+// UploadConfig 上传限制
+type UploadConfig struct {
+	MaxSize          ByteSize      `yaml:"max_size" env:"UPLOAD_MAX_SIZE" default:"10MB"`
+	ResumableMaxSize ByteSize      `yaml:"resumable_max_size" env:"UPLOAD_RESUMABLE_MAX_SIZE" default:"1GB"`
+	TmpDir           string        `yaml:"tmp_dir" env:"UPLOAD_TMP_DIR"` // 断点续传的临时目录，默认在系统临时目录下
+	SessionTTL       time.Duration `yaml:"session_ttl" env:"UPLOAD_SESSION_TTL" default:"24h"`
+}
+
+// ImageConfig 图片处理配置
+type ImageConfig struct {
+	Workers        int   `yaml:"workers" env:"IMAGE_WORKERS" default:"2"`
+	ThumbnailSizes []int `yaml:"thumbnail_sizes" env:"IMAGE_THUMBNAIL_SIZES" default:"128,512,1024"`
+}
+
+// Load 加载并校验配置，.env 不存在时只使用环境变量
+func Load() (*Config, error) {
+	cfg := &Config{}
+	if err := sharedconfig.Load(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Database.Port == 0 {
+		cfg.Database.Port = defaultDatabasePorts[cfg.Database.Driver]
+	}
+	deriveSecret(&cfg.Auth.CursorSecret, cfg.Auth.SecretKey, "cursor")
+	deriveSecret(&cfg.Auth.EmailTokenSecret, cfg.Auth.SecretKey, "email token")
+	deriveSecret(&cfg.Auth.MFASecretKey, cfg.Auth.SecretKey, "mfa secret key")
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验配置，返回所有不合法的项
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "PORT must be between 1 and 65535")
+	check(c.Server.ReadHeaderTimeout > 0, "READ_HEADER_TIMEOUT must be positive")
+	check(c.Server.ShutdownDelay >= 0, "SHUTDOWN_DELAY must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES contains invalid IP or CIDR %q", proxy)
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "LOG_LEVEL must be debug, info, warn or error")
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "LOG_FORMAT must be json or text")
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "OTEL_TRACES_EXPORTER must be none, stdout or otlp")
+	}
+	check(c.Tracing.ServiceName != "", "OTEL_SERVICE_NAME is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		check(c.Database.Host != "", "DB_HOST is required")
+		check(c.Database.Port > 0 && c.Database.Port < 65536, "DB_PORT must be between 1 and 65535")
+		check(c.Database.User != "", "DB_USER is required")
+		check(c.Database.Name != "", "DB_NAME is required")
+	case "sqlite":
+		check(c.Database.Name != "", "DB_NAME (the database file path) is required for sqlite")
+	default:
+		check(false, "DB_DRIVER must be mysql, postgres or sqlite")
+	}
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+	check(c.Database.ConnectRetries >= 0, "DB_CONNECT_RETRIES must not be negative")
+	check(c.Database.ConnectBackoff > 0, "DB_CONNECT_BACKOFF must be positive")
+	check(c.Database.SlowQueryThreshold >= 0, "DB_SLOW_QUERY_THRESHOLD must not be negative")
+
+	switch c.JWT.SigningAlg {
+	case "HS256":
+		check(c.JWT.Secret != "", "JWT_SECRET is required for HS256")
+	case "RS256", "EdDSA":
+		check(c.JWT.PrivateKeyFile != "", "JWT_PRIVATE_KEY_FILE is required for %s", c.JWT.SigningAlg)
+	default:
+		check(false, "JWT_SIGNING_ALG must be HS256, RS256 or EdDSA")
+	}
+	check(c.JWT.AccessTokenTTL > 0, "JWT_ACCESS_TOKEN_TTL must be positive")
+	check(c.JWT.RefreshTokenTTL > 0, "JWT_REFRESH_TOKEN_TTL must be positive")
+
+	// bcrypt 允许的成本范围为 4-31
+	check(c.Auth.BcryptCost >= 4 && c.Auth.BcryptCost <= 31, "BCRYPT_COST must be between 4 and 31")
+	check(c.Auth.VerifyEmailTTL > 0, "AUTH_VERIFY_EMAIL_TTL must be positive")
+	check(c.Auth.PasswordResetTTL > 0, "AUTH_PASSWORD_RESET_TTL must be positive")
+	check(c.Auth.MFAIssuer != "", "AUTH_MFA_ISSUER must not be empty")
+	// 各用途的密钥不能回退到 JWT_SECRET，JWT_SECRET 泄露时不应同时影响游标、邮件令牌和两步验证
+	secrets := []struct{ name, value string }{
+		{"AUTH_SECRET_KEY", c.Auth.SecretKey},
+		{"CURSOR_SECRET", c.Auth.CursorSecret},
+		{"AUTH_EMAIL_TOKEN_SECRET", c.Auth.EmailTokenSecret},
+		{"AUTH_MFA_SECRET_KEY", c.Auth.MFASecretKey},
+	}
+	for _, secret := range secrets[1:] {
+		check(secret.value != "", "%s is required when AUTH_SECRET_KEY is empty", secret.name)
+	}
+	for _, secret := range secrets {
+		check(secret.value == "" || secret.value != c.JWT.Secret, "%s must differ from JWT_SECRET", secret.name)
+	}
+	// 示例配置中的占位密钥和过短的密钥只能用于本地开发
+	if c.Server.Env != "development" {
+		if c.JWT.SigningAlg == "HS256" {
+			secrets = append(secrets, struct{ name, value string }{"JWT_SECRET", c.JWT.Secret})
+		}
+		for _, secret := range secrets {
+			check(strongSecret(secret.value), "%s must be set to a random value of at least %d characters when APP_ENV is not development", secret.name, minSecretLength)
+		}
+	}
+	check(c.Auth.MFAChallengeTTL > 0, "AUTH_MFA_CHALLENGE_TTL must be positive")
+
+	check(c.Lockout.Store == "memory" || c.Lockout.Store == "database", "LOCKOUT_STORE must be memory or database")
+	check(c.Lockout.AccountFailures >= 0, "LOCKOUT_ACCOUNT_FAILURES must not be negative")
+	check(c.Lockout.IPFailures >= 0, "LOCKOUT_IP_FAILURES must not be negative")
+	check(c.Lockout.BaseDuration > 0, "LOCKOUT_BASE_DURATION must be positive")
+	check(c.Lockout.MaxDuration >= c.Lockout.BaseDuration, "LOCKOUT_MAX_DURATION must not be less than LOCKOUT_BASE_DURATION")
+	check(c.Lockout.FailureWindow >= c.Lockout.MaxDuration, "LOCKOUT_FAILURE_WINDOW must not be less than LOCKOUT_MAX_DURATION")
+
+	switch c.RateLimit.Store {
+	case "memory":
+	case "redis":
+		check(c.RateLimit.RedisURL != "", "RATE_LIMIT_REDIS_URL is required when RATE_LIMIT_STORE=redis")
+	default:
+		problems = append(problems, "RATE_LIMIT_STORE must be memory or redis")
+	}
+	validBy := func(by string) bool { return by == "ip" || by == "user" }
+	check(validBy(c.RateLimit.AuthBy), "RATE_LIMIT_AUTH_BY must be ip or user")
+	check(validBy(c.RateLimit.APIBy), "RATE_LIMIT_API_BY must be ip or user")
+	check(validBy(c.RateLimit.UploadBy), "RATE_LIMIT_UPLOAD_BY must be ip or user")
+
+	switch c.Mail.Driver {
+	case "smtp":
+		check(c.Mail.SMTP.Host != "", "SMTP_HOST is required for smtp mail")
+		check(c.Mail.SMTP.Port > 0 && c.Mail.SMTP.Port < 65536, "SMTP_PORT must be between 1 and 65535")
+	case "file":
+		check(c.Mail.FileDir != "", "MAIL_FILE_DIR is required for file mail")
+	case "log":
+		// log 驱动不发送邮件，防止生产环境误用后用户收不到邮件而链接却出现在日志中
+		check(isLocalURL(c.Mail.LinkBaseURL), "MAIL_DRIVER=log is only for local development, MAIL_LINK_BASE_URL must point to localhost")
+	default:
+		check(false, "MAIL_DRIVER must be smtp, file or log")
+	}
+	check(c.Mail.From != "", "MAIL_FROM is required")
+	check(strings.HasPrefix(c.Mail.LinkBaseURL, "http://") || strings.HasPrefix(c.Mail.LinkBaseURL, "https://"),
+		"MAIL_LINK_BASE_URL must be an http or https URL")
+
+	switch c.Storage.Driver {
+	case "local":
+		check(c.Storage.LocalDir != "", "STORAGE_LOCAL_DIR is required for local storage")
+	case "s3":
+		check(c.Storage.S3.Endpoint != "" && c.Storage.S3.Bucket != "",
+			"S3_ENDPOINT and S3_BUCKET are required for s3 storage")
+		check(c.Storage.S3.AccessKey != "" && c.Storage.S3.SecretKey != "",
+			"S3_ACCESS_KEY and S3_SECRET_KEY are required for s3 storage")
+	case "memory":
+	default:
+		check(false, "STORAGE_DRIVER must be local, s3 or memory")
+	}
+
+	check(c.Upload.MaxSize > 0, "UPLOAD_MAX_SIZE must be positive")
+	check(c.Upload.ResumableMaxSize > 0, "UPLOAD_RESUMABLE_MAX_SIZE must be positive")
+	check(c.Upload.SessionTTL > 0, "UPLOAD_SESSION_TTL must be positive")
+
+	check(c.Image.Workers > 0, "IMAGE_WORKERS must be positive")
+	check(len(c.Image.ThumbnailSizes) > 0, "IMAGE_THUMBNAIL_SIZES must not be empty")
+	for _, size := range c.Image.ThumbnailSizes {
+		check(size > 0 && size <= 4096, "IMAGE_THUMBNAIL_SIZES must be between 1 and 4096, got %d", size)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// minSecretLength 非开发环境中密钥的最短长度，例如 openssl rand -hex 32 生成的 64 个字符
+const minSecretLength = 32
+
+// strongSecret 密钥不为空、不是示例中的 change-me 占位值且不短于 minSecretLength
+func strongSecret(value string) bool {
+	return len(value) >= minSecretLength && !strings.HasPrefix(strings.ToLower(value), "change-me")
+}
+
+// isLocalURL 地址是否指向本机，即主机名为 localhost 或回环地址
+func isLocalURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMailDriver(t *testing.T) {
+	setTestEnv(t)
+	t.Setenv("AUTH_SECRET_KEY", "master-secret")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mail.Driver != "smtp" {
+		t.Fatalf("default MAIL_DRIVER = %q, want smtp", cfg.Mail.Driver)
+	}
+
+	tests := []struct {
+		linkBaseURL string
+		ok          bool
+	}{
+		{"http://localhost:8080", true},
+		{"http://127.0.0.1:3000/app", true},
+		{"http://[::1]:8080", true},
+		{"http://app.localhost", true},
+		{"https://example.com", false},
+		{"https://localhost.example.com", false},
+		{"http://10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		cfg.Mail.Driver = "log"
+		cfg.Mail.LinkBaseURL = tt.linkBaseURL
+		err := cfg.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("log driver with %s: %v", tt.linkBaseURL, err)
+		}
+		if !tt.ok && (err == nil || !strings.Contains(err.Error(), "MAIL_DRIVER=log")) {
+			t.Errorf("log driver with %s error = %v, want a MAIL_DRIVER=log error", tt.linkBaseURL, err)
+		}
+	}
+}
+
+func TestValidateRejectsWeakSecretsOutsideDevelopment(t *testing.T) {
+	setTestEnv(t)
+	t.Setenv("APP_ENV", "production")
+	strong := strings.Repeat("a", 32)
+	t.Setenv("JWT_SECRET", strong)
+
+	tests := []struct {
+		name      string
+		authKey   string
+		jwtSecret string
+		invalid   string // 期望报错的变量，为空时应通过校验
+	}{
+		{"strong keys", strings.Repeat("b", 32), strong, ""},
+		{"empty auth key", "", strong, "AUTH_SECRET_KEY"},
+		{"placeholder auth key", "change-me-auth-secret-key-0123456789", strong, "AUTH_SECRET_KEY"},
+		{"placeholder in upper case", "CHANGE-ME-" + strings.Repeat("b", 32), strong, "AUTH_SECRET_KEY"},
+		{"short auth key", strings.Repeat("b", 31), strong, "AUTH_SECRET_KEY"},
+		{"short jwt secret", strings.Repeat("b", 32), "secretkey", "JWT_SECRET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_SECRET_KEY", tt.authKey)
+			t.Setenv("JWT_SECRET", tt.jwtSecret)
+			_, err := Load()
+			if tt.invalid == "" {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.invalid+" must be set to a random value") {
+				t.Fatalf("Load error = %v, want a weak %s error", err, tt.invalid)
+			}
+		})
+	}
+
+	// 开发环境允许使用短的本地密钥
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("AUTH_SECRET_KEY", "master-secret")
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load in development: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+package config
+
+import sharedconfig "shared/config"
+
+// ByteSize 字节数，见 shared/config.ByteSize
+type ByteSize = sharedconfig.ByteSize
+
+// Rate 限流速率，见 shared/config.Rate
+type Rate = sharedconfig.Rate
+
+// String 以 "路径 = 值" 的形式输出配置，secret 字段只显示是否已设置，可以安全地写入日志
+func (c *Config) String() string {
+	return sharedconfig.Format(c)
+}
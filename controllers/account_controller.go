@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"go_core/services"
+	"net/http"
+	"shared/apperr"
+	"shared/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emailRequest 重新发送验证邮件和忘记密码接口的请求体
+type emailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// accountTokenRequest 验证邮箱接口的请求体，令牌来自邮件中的链接
+type accountTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// resetPasswordRequest 重置密码接口的请求体
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max_bytes=72"`
+}
+
+// mailAcceptedMessage 无论邮箱是否已注册都返回相同的提示
+const mailAcceptedMessage = "If the email address is registered, a message has been sent to it"
+
+// VerifyEmail 使用邮件中的令牌验证邮箱
+func VerifyEmail(c *gin.Context) {
+	var req accountTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	if err := services.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Email verified successfully", nil))
+}
+
+// ResendVerificationEmail 重新发送验证邮件，之前的验证链接随即失效
+func ResendVerificationEmail(c *gin.Context) {
+	var req emailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	if err := services.ResendVerificationEmail(c.Request.Context(), req.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response.NewMessage(mailAcceptedMessage, nil))
+}
+
+// ForgotPassword 发送密码重置邮件
+func ForgotPassword(c *gin.Context) {
+	var req emailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	if err := services.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response.NewMessage(mailAcceptedMessage, nil))
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码，所有已登录的会话随即失效
+func ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	if err := services.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Password reset successfully", nil))
+}
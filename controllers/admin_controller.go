@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"go_core/services"
+	"net/http"
+	"shared/apperr"
+	"shared/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidUserID = apperr.BadRequest("invalid_user_id", "Invalid user ID")
+
+// ListRoles 获取所有角色及其权限
+func ListRoles(c *gin.Context) {
+	roles, err := services.ListRoles(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccess(roles))
+}
+
+// GrantRole 为用户授予角色
+func GrantRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	if err := services.GrantRole(c.Request.Context(), uint(userID), req.Role); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Role granted successfully", nil))
+}
+
+// RevokeRole 撤销用户的角色
+func RevokeRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if err := services.RevokeRole(c.Request.Context(), uint(userID), c.Param("role")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Role revoked successfully", nil))
+}
+
+// UnlockUser 解除用户因登录失败次数过多导致的锁定
+func UnlockUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	claims := currentClaims(c)
+	if err := services.UnlockUser(c.Request.Context(), claims.UserID, uint(userID), c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("User unlocked successfully", nil))
+}
+
+// ResetUserMFA 为丢失验证器和恢复码的用户关闭两步验证
+func ResetUserMFA(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	claims := currentClaims(c)
+	if err := services.ResetMFA(c.Request.Context(), claims.UserID, uint(userID), c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Two-factor authentication reset successfully", nil))
+}
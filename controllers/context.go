@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"go_core/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentClaims 获取 AuthMiddleware 写入上下文的当前用户信息
+func currentClaims(c *gin.Context) *services.Claims {
+	claims, _ := c.MustGet("user").(*services.Claims)
+	return claims
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"shared/buildinfo"
+	"shared/lifecycle"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Liveness 进程是否存活，不检查外部依赖，避免依赖故障时实例被反复重启
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness 实例是否可以接收流量，收到退出信号或任一依赖不可用时返回 503，负载均衡据此摘除实例
+func Readiness(c *gin.Context) {
+	if !lifecycle.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+
+	ok, checks := lifecycle.CheckReadiness(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
+
+// Version 返回构建信息
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"go_core/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS 公开 JWT 验证公钥，供其他服务验证 Token
+func JWKS(c *gin.Context) {
+	keys, err := services.JWKS()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"go_core/services"
+	"net/http"
+	"shared/apperr"
+	"shared/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mfaCodeRequest 需要提交验证码的两步验证接口的请求体，code 为 TOTP 验证码或恢复码
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// recoveryCodesResponse 恢复码只在生成时返回一次
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// GetMFAStatus 获取当前用户的两步验证状态
+func GetMFAStatus(c *gin.Context) {
+	claims := currentClaims(c)
+	status, err := services.GetMFAStatus(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccess(status))
+}
+
+// SetupMFA 开始绑定验证器应用，返回密钥和 otpauth:// 地址
+func SetupMFA(c *gin.Context) {
+	claims := currentClaims(c)
+	setup, err := services.StartMFASetup(c.Request.Context(), claims.UserID, claims.Email)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccess(setup))
+}
+
+// ConfirmMFA 提交验证器应用生成的验证码完成绑定，返回恢复码
+func ConfirmMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	claims := currentClaims(c)
+	codes, err := services.ConfirmMFASetup(c.Request.Context(), claims.UserID, req.Code, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Two-factor authentication enabled", recoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+// DisableMFA 校验验证码或恢复码后关闭两步验证
+func DisableMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	// 验证码错误与登录共用失败计数，令牌泄露时也无法暴力猜测验证码来关闭两步验证
+	claims := currentClaims(c)
+	if !checkLoginThrottle(c, claims.Email) {
+		return
+	}
+	if err := services.DisableMFA(c.Request.Context(), claims.UserID, req.Code, c.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			recordLoginFailure(c, claims.Email, &claims.UserID)
+		}
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Two-factor authentication disabled", nil))
+}
+
+// RegenerateRecoveryCodes 校验验证码或恢复码后重新生成恢复码
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	claims := currentClaims(c)
+	if !checkLoginThrottle(c, claims.Email) {
+		return
+	}
+	codes, err := services.RegenerateRecoveryCodes(c.Request.Context(), claims.UserID, req.Code, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			recordLoginFailure(c, claims.Email, &claims.UserID)
+		}
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Recovery codes regenerated", recoveryCodesResponse{RecoveryCodes: codes}))
+}
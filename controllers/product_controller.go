@@ -1,23 +1,34 @@
 package controllers
 
 import (
+	"fmt"
 	"go_core/models"
 	"go_core/services"
 	"net/http"
+	"shared/apperr"
+	"shared/response"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	errInvalidProductID = apperr.BadRequest("invalid_product_id", "Invalid product ID")
+	errInvalidIfMatch   = apperr.BadRequest("invalid_if_match", "Invalid If-Match header")
+	errVersionRequired  = apperr.New(http.StatusPreconditionRequired, "version_required", "version is required")
+)
+
 // GetProducts 获取产品列表（带分页）
 func GetProducts(c *gin.Context) {
 	// 调用服务层获取分页产品列表
 	products, pagination, err := services.GetProductsWithPagination(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Error fetching products"})
+		c.Error(err)
 		return
 	}
 
-	response := models.NewSuccessResponse(gin.H{
+	response := response.NewSuccess(gin.H{
 		"list":       products,
 		"pagination": pagination,
 	})
@@ -30,16 +41,171 @@ func CreateProduct(c *gin.Context) {
 	var product models.Product
 	// 将请求体中的数据绑定到 product 结构体
 	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		c.Error(apperr.InvalidInput(err))
 		return
 	}
 
 	// 调用服务层创建产品
-	if err := services.CreateProduct(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+	if err := services.CreateProduct(c.Request.Context(), &product, currentClaims(c)); err != nil {
+		c.Error(err)
 		return
 	}
 
 	// 返回创建成功的响应
-	c.JSON(http.StatusCreated, gin.H{"message": "Product created successfully", "product": product})
+	c.JSON(http.StatusCreated, response.NewMessage("Product created successfully", product))
+}
+
+// productUpdateRequest 整体更新产品的请求体
+type productUpdateRequest struct {
+	Name    string  `json:"name" binding:"required,max=255"`
+	Price   float64 `json:"price" binding:"gt=0"`
+	ImageID *uint   `json:"image_id"` // 为空时移除产品图片
+	Version uint    `json:"version"`
+}
+
+// productPatchRequest 部分更新产品的请求体，未传的字段保持不变
+type productPatchRequest struct {
+	Name    *string  `json:"name" binding:"omitempty,min=1,max=255"`
+	Price   *float64 `json:"price" binding:"omitempty,gt=0"`
+	ImageID *uint    `json:"image_id"` // 为 0 时移除产品图片
+	Version uint     `json:"version"`
+}
+
+// GetProduct 获取单个产品，ETag 为当前版本号
+func GetProduct(c *gin.Context) {
+	id, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+
+	product, err := services.GetProductByID(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setProductETag(c, product)
+	c.JSON(http.StatusOK, response.NewSuccess(product))
+}
+
+// UpdateProduct 整体更新产品，需要提供读取时的版本号
+func UpdateProduct(c *gin.Context) {
+	id, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+
+	var req productUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	version, ok := requireProductVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	product, err := services.UpdateProduct(c.Request.Context(), id, models.Product{Name: req.Name, Price: req.Price, ImageID: req.ImageID}, version, currentClaims(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setProductETag(c, product)
+	c.JSON(http.StatusOK, response.NewSuccess(product))
+}
+
+// PatchProduct 部分更新产品，需要提供读取时的版本号
+func PatchProduct(c *gin.Context) {
+	id, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+
+	var req productPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	version, ok := requireProductVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	product, err := services.PatchProduct(c.Request.Context(), id, req.Name, req.Price, req.ImageID, version, currentClaims(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setProductETag(c, product)
+	c.JSON(http.StatusOK, response.NewSuccess(product))
+}
+
+// DeleteProduct 软删除产品
+func DeleteProduct(c *gin.Context) {
+	id, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteProduct(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Product deleted successfully", nil))
+}
+
+// RestoreProduct 恢复被软删除的产品
+func RestoreProduct(c *gin.Context) {
+	id, ok := parseProductID(c)
+	if !ok {
+		return
+	}
+
+	product, err := services.RestoreProduct(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setProductETag(c, product)
+	c.JSON(http.StatusOK, response.NewSuccess(product))
+}
+
+// parseProductID 解析路径中的产品 ID，失败时记录 400 错误
+func parseProductID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.Error(errInvalidProductID)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// requireProductVersion 从 If-Match 头或请求体中获取版本号，两者都没有时记录 428 错误
+func requireProductVersion(c *gin.Context, bodyVersion uint) (uint, bool) {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+		version, err := strconv.ParseUint(tag, 10, 64)
+		if err != nil {
+			c.Error(errInvalidIfMatch)
+			return 0, false
+		}
+		return uint(version), true
+	}
+
+	if bodyVersion == 0 {
+		c.Error(errVersionRequired)
+		return 0, false
+	}
+	return bodyVersion, true
+}
+
+// setProductETag 将产品版本号作为 ETag 返回，便于客户端通过 If-Match 提交
+func setProductETag(c *gin.Context, product *models.Product) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, product.Version))
 }
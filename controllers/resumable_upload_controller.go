@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"go_core/models"
+	"go_core/services"
+	"net/http"
+	"shared/apperr"
+	"shared/response"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusVersion 兼容的 tus 协议版本（https://tus.io/protocols/resumable-upload）
+const tusVersion = "1.0.0"
+
+var (
+	errInvalidUploadLength   = apperr.BadRequest("invalid_upload_length", "Invalid Upload-Length header")
+	errInvalidUploadMetadata = apperr.BadRequest("invalid_upload_metadata", "Invalid Upload-Metadata header")
+	errInvalidUploadOffset   = apperr.BadRequest("invalid_upload_offset", "Invalid Upload-Offset header")
+	errInvalidChunkType      = apperr.New(http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/offset+octet-stream")
+)
+
+// CreateUpload 创建断点续传会话
+// 请求头 Upload-Length 为文件大小，Upload-Metadata 可包含 filename 和 checksum（完整文件的 SHA-256 十六进制）
+func CreateUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidUploadLength)
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.Error(errInvalidUploadMetadata)
+		return
+	}
+
+	session, err := services.CreateUploadSession(c.Request.Context(), currentClaims(c).UserID, length,
+		metadata["filename"], metadata["checksum"], c.GetInt64("upload_max_size"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	location := "/api/upload/resumable/" + session.ID
+	c.Header("Location", location)
+	setUploadHeaders(c, session)
+	c.JSON(http.StatusCreated, response.NewMessage("Upload created", gin.H{"upload": session, "url": location}))
+}
+
+// GetUploadOffset 查询上传进度（HEAD），客户端据此从 Upload-Offset 继续上传
+func GetUploadOffset(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	session, err := services.GetUploadSession(c.Request.Context(), c.Param("id"), currentClaims(c).UserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	setUploadHeaders(c, session)
+	c.Status(http.StatusOK)
+}
+
+// UploadChunk 上传一个分块（PATCH），Upload-Offset 必须等于已上传的字节数
+// 可通过 Upload-Checksum 校验分块，支持的算法见 services.UploadChecksumAlgorithms
+// 最后一个分块上传完成后返回 200 和文件信息，否则返回 204
+func UploadChunk(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Checksum-Algorithm", services.UploadChecksumAlgorithms)
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.Error(errInvalidChunkType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.Error(errInvalidUploadOffset)
+		return
+	}
+
+	session, err := services.GetUploadSession(c.Request.Context(), c.Param("id"), currentClaims(c).UserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	file, err := services.WriteUploadChunk(c.Request.Context(), session, offset, c.Request.Body, c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = services.ErrFileTooLarge
+		}
+		c.Error(err)
+		return
+	}
+
+	setUploadHeaders(c, session)
+	if file == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewMessage("File uploaded successfully", gin.H{
+		"file": file,
+		"url":  file.URL,
+	}))
+}
+
+// CancelUpload 取消上传（DELETE），删除已上传的数据
+func CancelUpload(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	session, err := services.GetUploadSession(c.Request.Context(), c.Param("id"), currentClaims(c).UserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := services.CancelUploadSession(c.Request.Context(), session); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// setUploadHeaders 返回上传进度和过期时间
+func setUploadHeaders(c *gin.Context, session *models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata 解析 Upload-Metadata 头，格式为逗号分隔的 "key base64(value)"
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
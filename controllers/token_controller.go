@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"go_core/services"
+	"net/http"
+	"shared/apperr"
+	"shared/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshTokenRequest 刷新和退出登录接口的请求体
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// bindRefreshToken 解析请求体中的刷新令牌，失败时记录 400 错误
+func bindRefreshToken(c *gin.Context) (string, bool) {
+	var req refreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return "", false
+	}
+	return req.RefreshToken, true
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对
+func RefreshToken(c *gin.Context) {
+	refreshToken, ok := bindRefreshToken(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := services.RotateRefreshToken(c.Request.Context(), refreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccess(tokens))
+}
+
+// Logout 退出登录，撤销刷新令牌所属的会话
+func Logout(c *gin.Context) {
+	refreshToken, ok := bindRefreshToken(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeRefreshToken(c.Request.Context(), refreshToken); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("Logged out successfully", nil))
+}
@@ -1,24 +1,117 @@
 package controllers
 
 import (
+	"errors"
+	"go_core/models"
+	"go_core/services"
+	"mime"
 	"net/http"
+	"path"
+	"shared/apperr"
+	"shared/response"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	errInvalidFile   = apperr.BadRequest("invalid_file", "Invalid file")
+	errInvalidFileID = apperr.BadRequest("invalid_file_id", "Invalid file ID")
+)
+
+// UploadFile 上传文件，大小限制由路由上的 UploadLimit 中间件决定
 func UploadFile(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid file"})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.Error(services.ErrFileTooLarge)
+			return
+		}
+		c.Error(errInvalidFile)
+		return
+	}
+
+	// 校验并保存文件
+	opts := services.UploadOptions{MaxSize: c.GetInt64("upload_max_size")}
+	saved, err := services.SaveUpload(c.Request.Context(), file, currentClaims(c).UserID, opts)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewMessage("File uploaded successfully", gin.H{
+		"file": saved,
+		"url":  saved.URL,
+	}))
+}
+
+// DownloadFile 下载文件，只有上传者或有 files:read_all 权限的用户可以访问
+func DownloadFile(c *gin.Context) {
+	file, ok := loadFile(c)
+	if !ok {
+		return
+	}
+
+	serveObject(c, file.StoredName, file.MimeType, file.Size, "attachment", file.OriginalName)
+}
+
+// DownloadFileVariant 下载图片的衍生版本（缩略图、WebP），权限与原图相同
+func DownloadFileVariant(c *gin.Context) {
+	file, ok := loadFile(c)
+	if !ok {
+		return
+	}
+
+	variant, err := services.FindVariant(file, c.Param("name"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ext := path.Ext(variant.StoredName)
+	filename := strings.TrimSuffix(file.OriginalName, path.Ext(file.OriginalName)) + "_" + variant.Name + ext
+	serveObject(c, variant.StoredName, variant.MimeType, variant.Size, "inline", filename)
+}
+
+// loadFile 解析路径中的文件 ID 并检查访问权限，失败时记录错误
+func loadFile(c *gin.Context) (*models.File, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidFileID)
+		return nil, false
+	}
+
+	file, err := services.GetFileForUser(c.Request.Context(), uint(id), currentClaims(c))
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return file, true
+}
+
+// serveObject 返回存储对象的内容，存储后端支持预签名时直接重定向，由存储服务提供下载
+func serveObject(c *gin.Context, storedName, mimeType string, size int64, disposition, filename string) {
+	url, err := services.PresignObject(c.Request.Context(), storedName)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if url != "" {
+		c.Redirect(http.StatusFound, url)
 		return
 	}
 
-	// Save the file to the server
-	filePath := "./uploads/" + file.Filename
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save file"})
+	reader, err := services.OpenObject(c.Request.Context(), storedName)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	defer reader.Close()
 
-	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully", "filePath": filePath})
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, size, mimeType, reader, map[string]string{
+		"Content-Disposition": mime.FormatMediaType(disposition, map[string]string{"filename": filename}),
+	})
 }
@@ -1,9 +1,15 @@
 package controllers
 
 import (
+	"errors"
 	"go_core/models"
 	"go_core/services"
 	"net/http"
+	"shared/apperr"
+	"shared/logging"
+	"shared/response"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,47 +18,182 @@ import (
 func RegisterUser(c *gin.Context) {
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		c.Error(apperr.InvalidInput(err))
 		return
 	}
 
 	// 调用服务层创建用户
-	if err := services.CreateUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+	created, err := services.CreateUser(c.Request.Context(), user)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
+	// 发送验证邮件，失败不影响注册，用户可以稍后重新发送
+	if err := services.SendVerificationEmail(c.Request.Context(), created); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("Failed to send verification email", "user_id", created.ID, "error", err)
+	}
+
+	c.JSON(http.StatusCreated, response.NewMessage("User created successfully", nil))
+}
+
+// loginRequest 登录接口的请求体，不使用 models.User 的注册校验规则，邮箱长度与注册时的上限一致
+type loginRequest struct {
+	Email    string `json:"email" binding:"required,max=255"`
+	Password string `json:"password" binding:"required"`
 }
 
 // Login 用户登录接口，生成 JWT Token
+// 开启两步验证的用户密码验证通过后只返回挑战令牌，需要再调用 LoginMFA 提交验证码
 func LoginUser(c *gin.Context) {
-	var user models.User
+	var user loginRequest
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid input"})
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx)
+
+	// 账号或 IP 失败次数过多时拒绝登录，锁定期间即使密码正确也不放行
+	if !checkLoginThrottle(c, user.Email) {
 		return
 	}
 
-	// 查找用户
-	dbUser, err := services.GetUserByEmail(user.Email)
+	// 查找用户，用户不存在和密码错误返回相同的错误，避免泄露邮箱是否已注册
+	dbUser, err := services.GetUserByEmail(ctx, user.Email)
+	if errors.Is(err, services.ErrUserNotFound) {
+		recordLoginFailure(c, user.Email, nil)
+		c.Error(services.ErrInvalidCredentials)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid email or password"})
+		c.Error(err)
 		return
 	}
 
 	// 验证密码
 	if !services.CheckPassword(dbUser.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid email or password"})
+		recordLoginFailure(c, user.Email, &dbUser.ID)
+		c.Error(services.ErrInvalidCredentials)
+		return
+	}
+
+	// 登录策略，例如要求邮箱已验证
+	if err := services.CheckLoginAllowed(dbUser); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 升级明文或弱成本的密码哈希，失败不影响本次登录
+	if err := services.RehashPasswordIfNeeded(ctx, dbUser, user.Password); err != nil {
+		logger.Warn("Failed to rehash password", "user_id", dbUser.ID, "error", err)
+	}
+
+	// 开启了两步验证时返回挑战令牌，失败计数在验证码通过后才清零
+	enabled, err := services.MFAEnabled(ctx, dbUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if enabled {
+		challenge, err := services.IssueMFAChallenge(ctx, dbUser.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, response.NewSuccess(challenge))
+		return
+	}
+
+	completeLogin(c, dbUser)
+}
+
+// loginMFARequest 两步验证登录接口的请求体，code 为 TOTP 验证码或恢复码
+type loginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required,max=32"`
+}
+
+// LoginMFA 两步验证登录接口，校验挑战令牌和验证码后生成 JWT Token
+func LoginMFA(c *gin.Context) {
+	var req loginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperr.InvalidInput(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	dbUser, err := services.VerifyMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 验证码错误计入同一账号的失败次数，防止暴力猜测验证码
+	if !checkLoginThrottle(c, dbUser.Email) {
+		return
+	}
+	if err := services.VerifyMFACode(ctx, dbUser.ID, req.Code, c.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			recordLoginFailure(c, dbUser.Email, &dbUser.ID)
+		}
+		c.Error(err)
+		return
+	}
+
+	// 验证码通过后消耗挑战令牌，并发提交时只有一个请求能完成登录
+	if err := services.ConsumeMFAChallenge(ctx, req.MFAToken); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// 生成 Token
-	token, err := services.GenerateToken(*dbUser)
+	// 挑战令牌签发后账号可能被禁止登录，例如管理员修改了登录策略
+	if err := services.CheckLoginAllowed(dbUser); err != nil {
+		c.Error(err)
+		return
+	}
+
+	completeLogin(c, dbUser)
+}
+
+// completeLogin 清零失败计数，开启新会话并返回访问令牌和刷新令牌
+func completeLogin(c *gin.Context, dbUser *models.User) {
+	ctx := c.Request.Context()
+	if err := services.RecordLoginSuccess(ctx, dbUser.Email); err != nil {
+		logging.FromContext(ctx).Warn("Failed to reset login failures", "user_id", dbUser.ID, "error", err)
+	}
+
+	tokens, err := services.IssueTokens(ctx, *dbUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
+		c.Error(err)
 		return
 	}
 
 	// 返回 token
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, response.NewSuccess(tokens))
+}
+
+// checkLoginThrottle 账号或 IP 处于锁定中时写入错误和 Retry-After，返回是否可以继续
+func checkLoginThrottle(c *gin.Context, email string) bool {
+	retryAfter, err := services.CheckLoginThrottle(c.Request.Context(), email, c.ClientIP())
+	if err == nil {
+		return true
+	}
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	}
+	c.Error(err)
+	return false
+}
+
+// recordLoginFailure 记录一次登录失败，写入失败只记录日志，不影响返回给客户端的错误
+func recordLoginFailure(c *gin.Context, email string, userID *uint) {
+	if err := services.RecordLoginFailure(c.Request.Context(), email, c.ClientIP(), userID); err != nil {
+		args := []interface{}{"error", err}
+		if userID != nil {
+			args = append(args, "user_id", *userID)
+		}
+		logging.FromContext(c.Request.Context()).Error("Failed to record login failure", args...)
+	}
 }
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	sharedconfig "shared/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 游戏服务的配置，由 shared/config.Load 按标签加载
+// 加载顺序（后者覆盖前者）：default 标签 -> CONFIG_FILE 指定的 YAML 文件 -> .env 和环境变量
+// env 标签可以列出多个变量名，按顺序取第一个有值的；secret 标签的字段在日志中会被隐藏
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Database  DatabaseConfig  `yaml:"database"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Port              int           `yaml:"port" env:"PORT" default:"8080"`
+	MetricsAddr       string        `yaml:"metrics_addr" env:"METRICS_ADDR" default:":9090"` // /metrics 的监听地址，与业务端口分开，不要通过负载均衡对外暴露；配置文件中设为空时不提供指标
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" default:"10s"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"5s"`      // 收到退出信号后先标记为未就绪，等待负载均衡摘除实例
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"` // 等待进行中的请求和 WebSocket 连接关闭的最长时间
+	TrustedProxies    []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`                 // 可信代理的 IP 或 CIDR，只采信它们转发的 X-Forwarded-For，为空时使用连接的对端地址
+}
+
+// Addr 监听地址
+func (c ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" default:"info"`   // debug、info、warn 或 error，debug 时记录所有 SQL
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json"` // json 或 text
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置，环境变量沿用 OpenTelemetry 的约定
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none"` // none、stdout 或 otlp，none 时只传播上游的 trace 上下文
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`  // OTLP/HTTP 的完整地址，为空时由 SDK 读取 OTEL_EXPORTER_OTLP_ENDPOINT
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"game_service"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" default:"1"` // 新链路的采样比例，上游已采样的请求始终记录
+}
+
+// DatabaseConfig 数据库连接配置，sqlite 只使用 Name 作为数据库文件路径
+// DB_PASS 是旧的变量名，与 go_core 统一为 DB_PASSWORD
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver" env:"DB_DRIVER" default:"mysql"` // mysql、postgres 或 sqlite
+	Host     string `yaml:"host" env:"DB_HOST" default:"localhost"`
+	Port     int    `yaml:"port" env:"DB_PORT"` // 为 0 时使用驱动的默认端口
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD,DB_PASS" secret:"true"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"ssl_mode" env:"DB_SSL_MODE" default:"disable"` // 仅 postgres 使用
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+
+	ConnectRetries int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES" default:"10"` // 启动时连接失败的重试次数
+	ConnectBackoff time.Duration `yaml:"connect_backoff" env:"DB_CONNECT_BACKOFF" default:"1s"` // 首次重试的等待时间，之后每次翻倍
+
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" default:"true"` // 启动时执行未执行的迁移，关闭后需先运行 migrate up
+
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" default:"200ms"` // 超过该时间的查询记为 warn，0 表示不记录
+}
+
+// RateLimitConfig 接口限流配置，按路由组分别使用令牌桶，按客户端 IP 计数
+type RateLimitConfig struct {
+	Store    string `yaml:"store" env:"RATE_LIMIT_STORE" default:"memory"`                // memory 或 redis，多副本部署时使用 redis
+	RedisURL string `yaml:"redis_url" env:"RATE_LIMIT_REDIS_URL,REDIS_URL" secret:"true"` // 例如 redis://:password@localhost:6379/0
+
+	API       Rate `yaml:"api" env:"RATE_LIMIT_API" default:"120/m"`            // 游戏和房间接口
+	WebSocket Rate `yaml:"websocket" env:"RATE_LIMIT_WEBSOCKET" default:"10/m"` // 建立 WebSocket 连接
+}
+
+// Rate 限流速率，见 shared/config.Rate
+type Rate = sharedconfig.Rate
+
+// 各驱动的默认端口
+var defaultDatabasePorts = map[string]int{
+	"mysql":    3306,
+	"postgres": 5432,
+}
+
+// Load 加载并校验配置，.env 不存在时只使用环境变量
+func Load() (*Config, error) {
+	cfg := &Config{}
+	if err := sharedconfig.Load(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Database.Port == 0 {
+		cfg.Database.Port = defaultDatabasePorts[cfg.Database.Driver]
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验配置，返回所有不合法的项
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		problems = append(problems, "PORT must be between 1 and 65535")
+	}
+	if c.Server.ReadHeaderTimeout <= 0 || c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "READ_HEADER_TIMEOUT and SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.Server.ShutdownDelay < 0 {
+		problems = append(problems, "SHUTDOWN_DELAY must not be negative")
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, "LOG_LEVEL must be debug, info, warn or error")
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		problems = append(problems, "LOG_FORMAT must be json or text")
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		problems = append(problems, "OTEL_TRACES_EXPORTER must be none, stdout or otlp")
+	}
+	if c.Tracing.ServiceName == "" {
+		problems = append(problems, "OTEL_SERVICE_NAME is required")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "OTEL_TRACES_SAMPLER_ARG must be between 0 and 1")
+	}
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		if c.Database.Host == "" {
+			problems = append(problems, "DB_HOST is required")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			problems = append(problems, "DB_PORT must be between 1 and 65535")
+		}
+		if c.Database.User == "" {
+			problems = append(problems, "DB_USER is required")
+		}
+		if c.Database.Name == "" {
+			problems = append(problems, "DB_NAME is required")
+		}
+	case "sqlite":
+		if c.Database.Name == "" {
+			problems = append(problems, "DB_NAME (the database file path) is required for sqlite")
+		}
+	default:
+		problems = append(problems, "DB_DRIVER must be mysql, postgres or sqlite")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 {
+		problems = append(problems, "DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME must not be negative")
+	}
+	if c.Database.ConnectRetries < 0 {
+		problems = append(problems, "DB_CONNECT_RETRIES must not be negative")
+	}
+	if c.Database.ConnectBackoff <= 0 {
+		problems = append(problems, "DB_CONNECT_BACKOFF must be positive")
+	}
+	if c.Database.SlowQueryThreshold < 0 {
+		problems = append(problems, "DB_SLOW_QUERY_THRESHOLD must not be negative")
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, fmt.Sprintf("TRUSTED_PROXIES contains invalid IP or CIDR %q", proxy))
+		}
+	}
+	switch c.RateLimit.Store {
+	case "memory":
+	case "redis":
+		if c.RateLimit.RedisURL == "" {
+			problems = append(problems, "RATE_LIMIT_REDIS_URL is required when RATE_LIMIT_STORE=redis")
+		}
+	default:
+		problems = append(problems, "RATE_LIMIT_STORE must be memory or redis")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// String 以 "路径 = 值" 的形式输出配置，密码等 secret 字段只显示是否已设置，可以安全地写入日志
+func (c *Config) String() string {
+	return sharedconfig.Format(c)
+}
@@ -1,30 +1,114 @@
 package config
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"shared/logging"
+	"strings"
+	"time"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func ConnectDB() {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASS"),
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_NAME"),
-	)
+// maxConnectBackoff 连接重试的最长等待时间
+const maxConnectBackoff = 30 * time.Second
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// ConnectDB 连接数据库并设置连接池，配置由 Load 加载
+// 连接失败时按指数退避重试，重试次数用完后返回错误
+func ConnectDB(cfg DatabaseConfig) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		db, err := connect(cfg)
+		if err == nil {
+			DB = db
+			slog.Info("数据库连接成功", "driver", cfg.Driver)
+			return nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return fmt.Errorf("连接 %s 失败，已尝试 %d 次: %w", cfg.Driver, attempt+1, err)
+		}
+		slog.Warn("无法连接到数据库，稍后重试",
+			"attempt", attempt+1, "max_attempts", cfg.ConnectRetries+1, "retry_in", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+// openDialector 根据驱动生成 GORM 的 Dialector
+func openDialector(cfg DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "mysql":
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+		return mysql.Open(dsn), nil
+	case "postgres":
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s TimeZone=Local",
+			cfg.Host, cfg.Port, cfg.User, quoteDSNValue(cfg.Password), cfg.Name, cfg.SSLMode)
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(sqliteDSN(cfg.Name)), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动 %q", cfg.Driver)
+	}
+}
+
+// sqliteDSN 为 SQLite 文件开启 WAL 和外键，锁冲突时等待而不是直接报错，并自动创建所在目录
+func sqliteDSN(name string) string {
+	if strings.Contains(name, "?") {
+		return name
+	}
+	if name != ":memory:" && !strings.HasPrefix(name, "file:") {
+		if dir := filepath.Dir(name); dir != "." {
+			_ = os.MkdirAll(dir, 0o755)
+		}
+	}
+	return name + "?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on"
+}
+
+// quoteDSNValue 为 postgres 连接串中的值加引号，支持包含空格或引号的密码
+func quoteDSNValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// connect 打开连接、设置连接池并确认数据库可用
+func connect(cfg DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := openDialector(cfg)
 	if err != nil {
-		log.Fatalf("无法连接到数据库: %v", err)
+		return nil, err
 	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logging.NewGormLogger(cfg.SlowQueryThreshold)})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.Driver == "sqlite" && strings.Contains(cfg.Name, ":memory:") {
+		// 内存数据库每个连接都是独立的，只能使用一个连接
+		sqlDB.SetMaxOpenConns(1)
+	}
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	DB = db
-	log.Println("数据库连接成功")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
 }
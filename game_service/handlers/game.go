@@ -1,13 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"game_service/config"
 	"game_service/models"
 	"net/http"
+	"shared/apperr"
+	"shared/response"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidGameID = apperr.BadRequest("invalid_game_id", "无效的游戏ID")
+	errGameNotFound  = apperr.NotFound("game_not_found", "游戏不存在")
 )
 
 // 创建游戏
@@ -15,27 +24,18 @@ func CreateGame(c *gin.Context) {
 	var game models.Game
 	// 绑定 JSON 数据到 game 结构体
 	if err := c.ShouldBindJSON(&game); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
-		return
-	}
-
-	// 校验游戏数据
-	if game.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game name is required"})
+		c.Error(apperr.InvalidInput(err))
 		return
 	}
 
 	// 保存游戏到数据库
-	if err := config.DB.Create(&game).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create game", "details": err.Error()})
+	if err := config.DB.WithContext(c.Request.Context()).Create(&game).Error; err != nil {
+		c.Error(fmt.Errorf("创建游戏失败: %w", err))
 		return
 	}
 
 	// 返回成功响应
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Game created successfully",
-		"game":    game,
-	})
+	c.JSON(http.StatusCreated, response.NewMessage("Game created successfully", game))
 }
 
 // 获取所有游戏
@@ -43,39 +43,41 @@ func GetGames(c *gin.Context) {
 	var games []models.Game
 
 	// 查询游戏，并计算每个游戏的房间数量
-	result := config.DB.Table("games").Select("games.id, games.name, games.status, COUNT(rooms.id) AS room_count").
+	result := config.DB.WithContext(c.Request.Context()).Table("games").Select("games.id, games.name, games.status, COUNT(rooms.id) AS room_count").
 		// 使用 LEFT JOIN 以确保即使没有房间也能返回游戏
 		Joins("LEFT JOIN rooms ON rooms.game_id = games.id").
 		Group("games.id").Find(&games)
 
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		c.Error(fmt.Errorf("查询游戏列表失败: %w", result.Error))
 		return
 	}
 
 	// 返回游戏和房间数量
-	c.JSON(http.StatusOK, games)
+	c.JSON(http.StatusOK, response.NewSuccess(games))
 }
 
 // 根据ID获取游戏
 func GetGameByID(c *gin.Context) {
 	id := c.Param("game_id")
-	fmt.Print(c)
 	// 将ID转换为整型
 	gameID, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的游戏ID"})
+		c.Error(errInvalidGameID)
 		return
 	}
 
 	var game models.Game
 	// 查询指定ID的游戏
-	if err := config.DB.First(&game, gameID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "游戏不存在"})
+	if err := config.DB.WithContext(c.Request.Context()).First(&game, gameID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = errGameNotFound
+		}
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, game)
+	c.JSON(http.StatusOK, response.NewSuccess(game))
 }
 
 // 删除游戏
@@ -85,15 +87,15 @@ func DeleteGame(c *gin.Context) {
 	// 将ID转换为整型
 	gameID, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的游戏ID"})
+		c.Error(errInvalidGameID)
 		return
 	}
 
 	// 删除游戏
-	if err := config.DB.Delete(&models.Game{}, gameID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除游戏失败"})
+	if err := config.DB.WithContext(c.Request.Context()).Delete(&models.Game{}, gameID).Error; err != nil {
+		c.Error(fmt.Errorf("删除游戏失败: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "游戏已删除"})
+	c.JSON(http.StatusOK, response.NewMessage("游戏已删除", nil))
 }
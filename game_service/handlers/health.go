@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"game_service/config"
+	"net/http"
+	"shared/buildinfo"
+	"shared/lifecycle"
+	"shared/migrate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterReadinessChecks 注册 /readyz 检查的依赖，需在连接数据库之后调用
+func RegisterReadinessChecks() {
+	lifecycle.OnReadiness("database", checkDatabase)
+	lifecycle.OnReadiness("migrations", checkMigrations)
+}
+
+// Liveness 进程是否存活，不检查外部依赖，避免依赖故障时实例被反复重启
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness 实例是否可以接收流量，收到退出信号或任一依赖不可用时返回 503，负载均衡据此摘除实例
+func Readiness(c *gin.Context) {
+	if !lifecycle.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+
+	ok, checks := lifecycle.CheckReadiness(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
+
+// Version 返回构建信息
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
+
+// checkDatabase 检查数据库连接是否可用
+func checkDatabase(ctx context.Context) error {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkMigrations 检查数据库迁移是否已全部执行
+func checkMigrations(ctx context.Context) error {
+	return migrate.CheckApplied(config.DB.WithContext(ctx))
+}
@@ -1,32 +1,49 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"game_service/config"
 	"game_service/models"
 	"net/http"
+	"shared/apperr"
+	"shared/response"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+var (
+	errRoomNotFound    = apperr.NotFound("room_not_found", "房间不存在或不属于该游戏")
+	errPlayerInRoom    = apperr.Conflict("player_in_room", "玩家已在房间中")
+	errRoomFull        = apperr.Conflict("room_full", "房间已满")
+	errPlayerNotInRoom = apperr.NotFound("player_not_in_room", "玩家不在房间中")
+)
+
+// playerRequest 加入和退出房间的请求体
+type playerRequest struct {
+	Username string `json:"username" binding:"required,max=64"` // 用户名
+}
+
 // 获取房间中的所有玩家
 func GetRoomPlayers(c *gin.Context) {
 	gameID := c.Param("game_id")
 	roomID := c.Param("room_id")
 
 	// 验证游戏是否存在
-	validatedGameID, err := validateGame(gameID)
+	validatedGameID, err := validateGame(c.Request.Context(), gameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效或不存在的游戏ID"})
+		c.Error(err)
 		return
 	}
 
-	var room models.Room
 	// 查询房间
-	if err := config.DB.First(&room, "id = ? AND game_id = ?", roomID, validatedGameID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "房间不存在或不属于该游戏"})
+	room, err := findRoom(c.Request.Context(), roomID, validatedGameID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -37,43 +54,58 @@ func GetRoomPlayers(c *gin.Context) {
 	}
 
 	// 返回玩家列表
-	c.JSON(http.StatusOK, gin.H{"room_id": room.ID, "players": players})
+	c.JSON(http.StatusOK, response.NewSuccess(gin.H{"room_id": room.ID, "players": players}))
 }
 
-// 验证游戏是否存在并返回其 uint 类型的 ID
-func validateGame(gameID string) (uint, error) {
+// 验证游戏是否存在并返回其 uint 类型的 ID，ID 不合法时返回 errInvalidGameID，不存在时返回 errGameNotFound
+func validateGame(ctx context.Context, gameID string) (uint, error) {
 	id, err := strconv.Atoi(gameID) // 将字符串转换为整数
 	if err != nil {
-		return 0, err
+		return 0, errInvalidGameID
 	}
 
 	var game models.Game
-	if err := config.DB.First(&game, id).Error; err != nil {
+	if err := config.DB.WithContext(ctx).First(&game, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errGameNotFound
+		}
 		return 0, err
 	}
 
 	return uint(id), nil
 }
 
+// 查询属于指定游戏的房间，不存在时返回 errRoomNotFound
+func findRoom(ctx context.Context, roomID string, gameID uint) (models.Room, error) {
+	var room models.Room
+	if err := config.DB.WithContext(ctx).First(&room, "id = ? AND game_id = ?", roomID, gameID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return room, errRoomNotFound
+		}
+		return room, err
+	}
+	return room, nil
+}
+
 // 获取指定游戏的房间列表
 func GetRooms(c *gin.Context) {
 	gameID := c.Param("game_id")
 
 	// 验证游戏是否存在
-	validatedGameID, err := validateGame(gameID)
+	validatedGameID, err := validateGame(c.Request.Context(), gameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效或不存在的游戏ID"})
+		c.Error(err)
 		return
 	}
 
 	var rooms []models.Room
 	// 查询指定游戏的房间
-	if err := config.DB.Where("game_id = ?", validatedGameID).Find(&rooms).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取房间列表失败"})
+	if err := config.DB.WithContext(c.Request.Context()).Where("game_id = ?", validatedGameID).Find(&rooms).Error; err != nil {
+		c.Error(fmt.Errorf("获取房间列表失败: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, rooms)
+	c.JSON(http.StatusOK, response.NewSuccess(rooms))
 }
 
 // 创建房间
@@ -81,15 +113,15 @@ func CreateRoom(c *gin.Context) {
 	gameID := c.Param("game_id")
 
 	// 验证游戏是否存在
-	validatedGameID, err := validateGame(gameID)
+	validatedGameID, err := validateGame(c.Request.Context(), gameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效或不存在的游戏ID"})
+		c.Error(err)
 		return
 	}
 
 	var room models.Room
 	if err := c.ShouldBindJSON(&room); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperr.InvalidInput(err))
 		return
 	}
 
@@ -97,32 +129,32 @@ func CreateRoom(c *gin.Context) {
 	room.GameID = validatedGameID
 
 	// 保存房间
-	if err := config.DB.Create(&room).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建房间失败"})
+	if err := config.DB.WithContext(c.Request.Context()).Create(&room).Error; err != nil {
+		c.Error(fmt.Errorf("创建房间失败: %w", err))
 		return
 	}
 
 	// 更新游戏的房间数量
-	if err := updateGameRoomCount(validatedGameID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新游戏房间数量失败"})
+	if err := updateGameRoomCount(c.Request.Context(), validatedGameID); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, room)
+	c.JSON(http.StatusCreated, response.NewSuccess(room))
 }
 
 // 更新游戏的房间数量
-func updateGameRoomCount(gameID uint) error {
+func updateGameRoomCount(ctx context.Context, gameID uint) error {
 	var game models.Game
 	// 获取游戏并更新房间数量
-	if err := config.DB.First(&game, gameID).Error; err != nil {
-		return fmt.Errorf("游戏不存在: %v", err)
+	if err := config.DB.WithContext(ctx).First(&game, gameID).Error; err != nil {
+		return fmt.Errorf("游戏不存在: %w", err)
 	}
 
 	// 更新房间数量
 	game.RoomCount++ // 假设数据库中有一个 `RoomCount` 字段
-	if err := config.DB.Save(&game).Error; err != nil {
-		return fmt.Errorf("更新游戏房间数量失败: %v", err)
+	if err := config.DB.WithContext(ctx).Save(&game).Error; err != nil {
+		return fmt.Errorf("更新游戏房间数量失败: %w", err)
 	}
 
 	return nil
@@ -133,27 +165,25 @@ func JoinRoom(c *gin.Context) {
 	gameID := c.Param("game_id")
 	roomID := c.Param("room_id")
 
-	var user struct {
-		Username string `json:"username"` // 用户名
-	}
+	var user playerRequest
 
 	// 获取用户请求中的用户名
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户信息"})
+		c.Error(apperr.InvalidInput(err))
 		return
 	}
 
 	// 验证游戏是否存在
-	validatedGameID, err := validateGame(gameID)
+	validatedGameID, err := validateGame(c.Request.Context(), gameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效或不存在的游戏ID"})
+		c.Error(err)
 		return
 	}
 
-	var room models.Room
 	// 查询房间
-	if err := config.DB.First(&room, "id = ? AND game_id = ?", roomID, validatedGameID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "房间不存在或不属于该游戏"})
+	room, err := findRoom(c.Request.Context(), roomID, validatedGameID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -164,21 +194,21 @@ func JoinRoom(c *gin.Context) {
 		players = []string{} // 初始化为空切片
 	} else {
 		if err := json.Unmarshal([]byte(room.Players), &players); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "解析玩家列表失败"})
+			c.Error(fmt.Errorf("解析玩家列表失败: %w", err))
 			return
 		}
 
 		// 检查玩家是否已经在房间中
 		for _, player := range players {
 			if player == user.Username {
-				c.JSON(http.StatusConflict, gin.H{"error": "玩家已在房间中"})
+				c.Error(errPlayerInRoom)
 				return
 			}
 		}
 
 		// 检查房间是否已满
 		if len(players) >= room.MaxSeats {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "房间已满"})
+			c.Error(errRoomFull)
 			return
 		}
 
@@ -190,24 +220,23 @@ func JoinRoom(c *gin.Context) {
 	// 更新房间的 Players 字段
 	updatedPlayers, err := json.Marshal(players)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新玩家列表失败"})
+		c.Error(fmt.Errorf("更新玩家列表失败: %w", err))
 		return
 	}
 
 	room.Players = string(updatedPlayers)
 
 	// 保存更新后的房间数据
-	if err := config.DB.Save(&room).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存房间信息失败"})
+	if err := config.DB.WithContext(c.Request.Context()).Save(&room).Error; err != nil {
+		c.Error(fmt.Errorf("保存房间信息失败: %w", err))
 		return
 	}
 
 	// 返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"message": "玩家成功加入房间",
+	c.JSON(http.StatusOK, response.NewMessage("玩家成功加入房间", gin.H{
 		"room_id": room.ID,
 		"players": players,
-	})
+	}))
 }
 
 // 用户退出房间
@@ -215,28 +244,24 @@ func LeaveRoom(c *gin.Context) {
 	gameID := c.Param("game_id")
 	roomID := c.Param("room_id")
 
-	// 获取退出房间的用户信息
-	var user struct {
-		Username string `json:"username"` // 用户名
-	}
-
-	// 如果请求体中没有传递用户名，则返回错误
-	if err := c.ShouldBindJSON(&user); err != nil || user.Username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "请求体中缺少用户名信息"})
+	// 获取退出房间的用户信息，请求体中没有传递用户名时返回错误
+	var user playerRequest
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.Error(apperr.InvalidInput(err))
 		return
 	}
 
 	// 验证游戏是否存在
-	validatedGameID, err := validateGame(gameID)
+	validatedGameID, err := validateGame(c.Request.Context(), gameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效或不存在的游戏ID"})
+		c.Error(err)
 		return
 	}
 
-	var room models.Room
 	// 查询房间
-	if err := config.DB.First(&room, "id = ? AND game_id = ?", roomID, validatedGameID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "房间不存在或不属于该游戏"})
+	room, err := findRoom(c.Request.Context(), roomID, validatedGameID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -246,7 +271,7 @@ func LeaveRoom(c *gin.Context) {
 		players = []string{} // 如果 Players 字段为空，初始化为空切片
 	} else {
 		if err := json.Unmarshal([]byte(room.Players), &players); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "解析玩家列表失败"})
+			c.Error(fmt.Errorf("解析玩家列表失败: %w", err))
 			return
 		}
 	}
@@ -263,31 +288,30 @@ func LeaveRoom(c *gin.Context) {
 	}
 
 	if !playerFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "玩家不在房间中"})
+		c.Error(errPlayerNotInRoom)
 		return
 	}
 
 	// 更新房间的 Players 字段
 	updatedPlayers, err := json.Marshal(players)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新玩家列表失败"})
+		c.Error(fmt.Errorf("更新玩家列表失败: %w", err))
 		return
 	}
 
 	room.Players = string(updatedPlayers)
 
 	// 保存更新后的房间数据
-	if err := config.DB.Save(&room).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存房间信息失败"})
+	if err := config.DB.WithContext(c.Request.Context()).Save(&room).Error; err != nil {
+		c.Error(fmt.Errorf("保存房间信息失败: %w", err))
 		return
 	}
 
 	// 返回成功响应
-	c.JSON(http.StatusOK, gin.H{
-		"message": "玩家成功退出房间",
+	c.JSON(http.StatusOK, response.NewMessage("玩家成功退出房间", gin.H{
 		"room_id": room.ID,
 		"players": players,
-	})
+	}))
 }
 
 // 删除房间
@@ -296,17 +320,17 @@ func DeleteRoom(c *gin.Context) {
 	roomID := c.Param("room_id")
 
 	// 验证游戏是否存在
-	validatedGameID, err := validateGame(gameID)
+	validatedGameID, err := validateGame(c.Request.Context(), gameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效或不存在的游戏ID"})
+		c.Error(err)
 		return
 	}
 
 	// 删除房间
-	if err := config.DB.Where("id = ? AND game_id = ?", roomID, validatedGameID).Delete(&models.Room{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除房间失败"})
+	if err := config.DB.WithContext(c.Request.Context()).Where("id = ? AND game_id = ?", roomID, validatedGameID).Delete(&models.Room{}).Error; err != nil {
+		c.Error(fmt.Errorf("删除房间失败: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "房间已删除"})
+	c.JSON(http.StatusOK, response.NewMessage("房间已删除", nil))
 }
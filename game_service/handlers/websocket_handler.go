@@ -1,17 +1,43 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"shared/logging"
+	"shared/tracing"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// 用于存储所有活动连接的全局变量
-var clients = make(map[*websocket.Conn]bool)
+// 用于存储所有活动连接的全局变量，每个连接在独立的协程中处理，读写需持有 clientsMu
+var (
+	clientsMu    sync.Mutex
+	clients      = make(map[*websocket.Conn]bool)
+	shuttingDown bool // 服务正在退出，不再接受新连接
+)
+
+// 当前的 WebSocket 连接数，抓取指标时读取 clients
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "websocket_connections",
+	Help: "Number of open WebSocket connections.",
+}, func() float64 {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	return float64(len(clients))
+})
+
+// closeGracePeriod 发送关闭帧后等待客户端回应的最长时间
+const closeGracePeriod = 5 * time.Second
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		// 默认接受所有来源的连接
@@ -21,17 +47,25 @@ var upgrader = websocket.Upgrader{
 
 // WebSocket 连接处理
 func WebSocketHandler(c *gin.Context) {
+	// 连接的日志沿用握手请求的 request_id
+	ctx := c.Request.Context()
+	logger := logging.FromContext(ctx)
+
 	// 升级 HTTP 请求为 WebSocket 连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println("WebSocket Upgrade Error: ", err)
+		logger.Warn("WebSocket 握手失败", "error", err)
 		return
 	}
 	defer conn.Close()
 
-	// 将连接添加到客户端列表
-	clients[conn] = true
-	fmt.Println("New WebSocket connection established")
+	// 将连接添加到客户端列表，服务退出过程中直接关闭新连接
+	if !addClient(conn) {
+		sendClose(conn)
+		return
+	}
+	defer removeClient(conn)
+	logger.Info("WebSocket 连接已建立", "remote_addr", conn.RemoteAddr().String())
 
 	// 处理来自客户端的消息
 	for {
@@ -39,17 +73,25 @@ func WebSocketHandler(c *gin.Context) {
 		messageType, p, err := conn.ReadMessage()
 		if err != nil {
 			// 如果连接关闭或发生错误，退出循环
-			fmt.Println("Connection closed or error: ", err)
-			delete(clients, conn)
+			logger.Info("WebSocket 连接已断开", "reason", err.Error())
 			break
 		}
 
+		// 每条消息单独成链，通过 link 关联到握手请求的链路，避免连接期间的所有消息挤在一条长链路中
+		_, span := tracing.Tracer().Start(ctx, "websocket message",
+			trace.WithNewRoot(),
+			trace.WithLinks(trace.LinkFromContext(ctx)),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.Int("websocket.message_size", len(p))),
+		)
+
 		// 如果接收到的是文本消息
 		if messageType == websocket.TextMessage {
 			// 先判断消息是否为普通字符串
 			if isValidString(p) {
 				// 如果是普通字符串，直接处理
-				fmt.Println("Received plain string message:", string(p))
+				logger.Debug("收到文本消息", "content", string(p))
+				span.SetAttributes(attribute.String("websocket.message_kind", "text"))
 				response := Message{
 					Type:    "response",
 					Content: "Received plain string: " + string(p),
@@ -62,7 +104,8 @@ func WebSocketHandler(c *gin.Context) {
 				err := json.Unmarshal(p, &msg)
 				if err != nil {
 					// 如果解析失败，返回错误信息
-					fmt.Println("Error parsing message as JSON:", err)
+					logger.Debug("消息不是合法的 JSON", "error", err)
+					span.SetAttributes(attribute.String("websocket.message_kind", "invalid"))
 					response := Message{
 						Type:    "error",
 						Content: "Invalid message format",
@@ -71,7 +114,8 @@ func WebSocketHandler(c *gin.Context) {
 					conn.WriteMessage(websocket.TextMessage, responseJSON)
 				} else {
 					// 解析成功，处理 JSON 消息
-					fmt.Println("Received JSON message:", msg)
+					logger.Debug("收到 JSON 消息", "content", msg)
+					span.SetAttributes(attribute.String("websocket.message_kind", "json"))
 					response := Message{
 						Type:    "response",
 						Content: "Received JSON message",
@@ -81,6 +125,74 @@ func WebSocketHandler(c *gin.Context) {
 				}
 			}
 		}
+		span.End()
+	}
+}
+
+// addClient 记录新连接，服务正在退出时返回 false
+func addClient(conn *websocket.Conn) bool {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if shuttingDown {
+		return false
+	}
+	clients[conn] = true
+	return true
+}
+
+// removeClient 连接断开后移除
+func removeClient(conn *websocket.Conn) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	delete(clients, conn)
+}
+
+// sendClose 发送 1001 (Going Away) 关闭帧，客户端据此知道应当重连而不是报错
+func sendClose(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	// WriteControl 可以与处理协程中的 WriteMessage 并发调用
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// CloseWebSockets 服务退出时向所有客户端发送关闭帧，等待客户端回应后断开
+// 超过 closeGracePeriod 或 ctx 到期仍未断开的连接会被强制关闭
+func CloseWebSockets(ctx context.Context) error {
+	clientsMu.Lock()
+	shuttingDown = true
+	conns := make([]*websocket.Conn, 0, len(clients))
+	for conn := range clients {
+		conns = append(conns, conn)
+	}
+	clientsMu.Unlock()
+
+	for _, conn := range conns {
+		sendClose(conn)
+	}
+
+	// 客户端回应关闭帧后，ReadMessage 返回错误，处理协程会移除连接
+	ctx, cancel := context.WithTimeout(ctx, closeGracePeriod)
+	defer cancel()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		clientsMu.Lock()
+		remaining := len(clients)
+		clientsMu.Unlock()
+		if remaining == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			clientsMu.Lock()
+			for conn := range clients {
+				conn.Close()
+			}
+			clientsMu.Unlock()
+			slog.Warn("强制关闭未响应的 WebSocket 连接", "count", remaining)
+			return nil
+		}
 	}
 }
 
@@ -1,35 +1,153 @@
 package main
 
 import (
+	"context"
 	"game_service/config"
-	"game_service/models"
+	"game_service/handlers"
+	_ "game_service/migrations"
 	"game_service/routes"
+	"game_service/validation"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"shared/apperr"
+	"shared/lifecycle"
+	"shared/logging"
+	"shared/metrics"
+	"shared/migrate"
+	"shared/ratelimit"
+	"shared/tracing"
 
-	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
-	// 加载环境变量
-	if err := godotenv.Load(); err != nil {
-		log.Println("未找到 .env 文件，使用默认环境变量")
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("配置加载失败: %v", err)
 	}
 
+	// 结构化日志，标准库 log 的输出也会经过它
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	migrate.SetLockName("game_service_schema_migrations")
+
+	// 数据库迁移子命令：game_service migrate up|down [n]|status|create <name>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		connect := func() (*gorm.DB, error) {
+			if err := config.ConnectDB(cfg.Database); err != nil {
+				return nil, err
+			}
+			return config.DB, nil
+		}
+		if err := migrate.Run(os.Args[2:], connect, os.Stdout); err != nil {
+			fatal("迁移命令执行失败", err)
+		}
+		return
+	}
+
+	slog.Info("配置加载完成", "config", cfg.String())
+
+	// 链路追踪，最先注册的清理函数最后执行，退出前导出剩余的 span
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config(cfg.Tracing))
+	if err != nil {
+		fatal("链路追踪初始化失败", err)
+	}
+	lifecycle.OnShutdown("链路追踪", shutdownTracing)
+
 	// 初始化数据库
-	config.ConnectDB()
+	if err := config.ConnectDB(cfg.Database); err != nil {
+		fatal("无法连接到数据库", err)
+	}
+	// 退出时最后关闭连接池
+	lifecycle.OnShutdown("数据库连接池", func(ctx context.Context) error {
+		sqlDB, err := config.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+
+	// 记录查询耗时和连接池状态，通过 METRICS_ADDR 上的 /metrics 暴露；请求中的查询同时记录为 span
+	if err := metrics.InstrumentDB(config.DB, "game_service"); err != nil {
+		fatal("数据库监控初始化失败", err)
+	}
+	if err := tracing.InstrumentDB(config.DB); err != nil {
+		fatal("数据库监控初始化失败", err)
+	}
 
-	// 自动迁移数据库
-	if err := config.DB.AutoMigrate(&models.Room{}); err != nil {
-		log.Fatalf("数据库迁移失败: %v", err)
+	// 执行数据库迁移，多个副本同时启动时由迁移锁保证只执行一次
+	if cfg.Database.AutoMigrate {
+		if err := migrate.Up(config.DB); err != nil {
+			fatal("数据库迁移失败", err)
+		}
+	} else if pending, err := migrate.Pending(config.DB); err != nil {
+		fatal("检查数据库迁移失败", err)
+	} else if len(pending) > 0 {
+		slog.Error("数据库有未执行的迁移，请先运行 migrate up", "pending", len(pending))
+		os.Exit(1)
 	}
-	// 在 ConnectDB() 函数中添加：
-	if err := config.DB.AutoMigrate(&models.Game{}); err != nil {
-		log.Fatalf("数据库迁移失败: %v", err)
+	handlers.RegisterReadinessChecks()
+
+	// WebSocket 连接不受 http.Server.Shutdown 管理，退出时单独发送关闭帧
+	lifecycle.OnShutdown("WebSocket 连接", handlers.CloseWebSockets)
+
+	// 通用错误的提示信息使用中文，与业务错误一致
+	apperr.SetMessages(apperr.Messages{
+		InvalidInput:    "请求参数不合法",
+		FieldRule:       "不满足 %s 规则",
+		FieldType:       "类型应为 %s",
+		RequestTooLarge: "请求体过大",
+		RateLimited:     "请求过于频繁，请稍后再试",
+		Internal:        "服务器内部错误",
+	})
+
+	// 请求参数校验的自定义规则和中英文错误信息
+	if err := validation.Setup(); err != nil {
+		fatal("参数校验初始化失败", err)
 	}
 
-	// 设置路由并启动服务
-	router := routes.SetupRouter()
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("服务启动失败: %v", err)
+	// 接口限流的令牌桶存储，redis 驱动时退出前关闭连接
+	limiter, err := ratelimit.New(ratelimit.Config{Driver: cfg.RateLimit.Store, RedisURL: cfg.RateLimit.RedisURL})
+	if err != nil {
+		fatal("限流存储初始化失败", err)
 	}
+	lifecycle.OnShutdown("限流存储", func(ctx context.Context) error {
+		return limiter.Close()
+	})
+
+	// Prometheus 指标在单独的端口上提供，不经过业务路由
+	if cfg.Server.MetricsAddr != "" {
+		metricsServer, err := metrics.Serve(cfg.Server.MetricsAddr)
+		if err != nil {
+			fatal("指标服务启动失败", err)
+		}
+		lifecycle.OnShutdown("指标服务", metricsServer.Shutdown)
+	}
+
+	// 设置路由并启动服务，收到 SIGTERM 后等待进行中的请求完成再退出
+	router, err := routes.SetupRouter(cfg, limiter)
+	if err != nil {
+		fatal("路由初始化失败", err)
+	}
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr(),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	if err := lifecycle.Serve(srv, lifecycle.Config{ShutdownDelay: cfg.Server.ShutdownDelay, ShutdownTimeout: cfg.Server.ShutdownTimeout}); err != nil {
+		fatal("服务运行失败", err)
+	}
+}
+
+// fatal 记录错误日志并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 初始表结构，与改用版本化迁移前 AutoMigrate 创建的表一致
+// 这里的结构体是当时模型的副本，之后修改 models 不影响本迁移，表结构的变更需要新增迁移
+func init() {
+	migrate.Register(migrate.Migration{
+		Version: 20261018090000,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			type room struct {
+				ID       uint `gorm:"primaryKey"`
+				Name     string
+				Players  string
+				MaxSeats int
+				GameID   uint
+			}
+			type game struct {
+				ID        uint `gorm:"primaryKey"`
+				Name      string
+				Status    string
+				RoomCount int
+			}
+			return tx.AutoMigrate(&room{}, &game{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("rooms", "games")
+		},
+	})
+}
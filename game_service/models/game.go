@@ -3,7 +3,7 @@ package models
 // Game 模型，表示一个游戏
 type Game struct {
 	ID        uint   `json:"id" gorm:"primaryKey"`
-	Name      string `json:"name"`
+	Name      string `json:"name" binding:"required,max=100"`
 	Status    string `json:"status"`
 	RoomCount int    `json:"room_count"`
 }
@@ -1,16 +1,34 @@
 package routes
 
 import (
+	"game_service/config"
 	"game_service/handlers"
+	sharedmw "shared/middlewares"
+	"shared/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+// SetupRouter 注册中间件和路由，limiter 为限流令牌桶的存储
+func SetupRouter(cfg *config.Config, limiter ratelimit.Store) (*gin.Engine, error) {
+	router := gin.New()
+	// 只采信可信代理转发的客户端地址，否则 X-Forwarded-For 可以伪造，绕过按 IP 的限流
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.Use(sharedmw.RequestID(), sharedmw.Tracing(), sharedmw.Logger(nil), sharedmw.Metrics(), sharedmw.Recovery(), sharedmw.ErrorHandler())
+	router.NoRoute(sharedmw.NotFound("接口不存在"))
+
+	// 限流，每个路由组使用独立的令牌桶
+	limits := cfg.RateLimit
+	rateLimit := func(group string, rate config.Rate) gin.HandlerFunc {
+		limit := ratelimit.Limit{Burst: rate.Limit, Period: rate.Period}
+		return sharedmw.RateLimit(limiter, group, limit, sharedmw.ClientIPKey)
+	}
 
 	// 游戏路由
 	gameRoutes := router.Group("/games")
+	gameRoutes.Use(rateLimit("api", limits.API))
 	{
 		gameRoutes.GET("/", handlers.GetGames)              // 获取所有游戏
 		gameRoutes.POST("/", handlers.CreateGame)           // 创建游戏
@@ -30,6 +48,11 @@ func SetupRouter() *gin.Engine {
 	}
 
 	// WebSocket 路由
-	router.GET("/ws", handlers.WebSocketHandler) // WebSocket 路由
-	return router
+	router.GET("/ws", rateLimit("websocket", limits.WebSocket), handlers.WebSocketHandler) // WebSocket 路由，限制建立连接的频率
+
+	// 健康检查：存活、就绪（退出过程中或依赖不可用时返回 503）和构建信息，Prometheus 指标在 METRICS_ADDR 上单独提供
+	router.GET("/healthz", handlers.Liveness)
+	router.GET("/readyz", handlers.Readiness)
+	router.GET("/version", handlers.Version)
+	return router, nil
 }
@@ -0,0 +1,13 @@
+package validation
+
+import (
+	"game_service/models"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validSeats 房间座位数在 models.MinRoomSeats 和 models.MaxRoomSeats 之间
+func validSeats(fl validator.FieldLevel) bool {
+	seats := fl.Field().Int()
+	return seats >= models.MinRoomSeats && seats <= models.MaxRoomSeats
+}
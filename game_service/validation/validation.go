@@ -0,0 +1,23 @@
+// Package validation game_service 的自定义校验规则，校验引擎和中英文错误信息见 shared/validation
+// 客户端语言不受支持时错误信息使用中文
+package validation
+
+import (
+	"fmt"
+	"game_service/models"
+	sharedvalidation "shared/validation"
+)
+
+var rules = []sharedvalidation.Rule{
+	{
+		Tag:  "seats",
+		Func: validSeats,
+		En:   fmt.Sprintf("{0} must be between %d and %d", models.MinRoomSeats, models.MaxRoomSeats),
+		Zh:   fmt.Sprintf("{0}必须在%d到%d之间", models.MinRoomSeats, models.MaxRoomSeats),
+	},
+}
+
+// Setup 注册 game_service 的校验规则，需在处理请求前调用一次
+func Setup() error {
+	return sharedvalidation.Setup("zh", rules...)
+}
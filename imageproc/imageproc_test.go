@@ -0,0 +1,312 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// gradient 生成渐变测试图片
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// encode 按 MIME 类型编码图片
+func encode(t *testing.T, img image.Image, mimeType string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, mimeType); err != nil {
+		t.Fatalf("Encode(%s): %v", mimeType, err)
+	}
+	return buf.Bytes()
+}
+
+// exifSegment 构造只包含方向标签的 APP1 EXIF 段
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("II*\x00")
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withJPEGSegments 在 SOI 之后插入数据段
+func withJPEGSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+// riffChunk 构造 WebP 的 RIFF 数据块，奇数长度补一个字节
+func riffChunk(fourCC string, payload []byte) []byte {
+	chunk := []byte(fourCC)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		size          int
+		wantW, wantH  int
+	}{
+		{"landscape", 2000, 1000, 512, 512, 256},
+		{"portrait", 1000, 2000, 512, 256, 512},
+		{"square", 800, 800, 128, 128, 128},
+		{"thin", 4000, 2, 128, 128, 1},
+		{"smaller than size", 100, 50, 512, 100, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb := Thumbnail(gradient(tt.width, tt.height), tt.size)
+			if got := thumb.Bounds(); got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+				t.Fatalf("Thumbnail = %dx%d, want %dx%d", got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	src := gradient(64, 48)
+	for _, mimeType := range []string{"image/jpeg", "image/png", "image/gif", "image/webp"} {
+		t.Run(mimeType, func(t *testing.T) {
+			img, err := Decode(encode(t, src, mimeType))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if img.Bounds().Dx() != 64 || img.Bounds().Dy() != 48 {
+				t.Fatalf("decoded size = %v, want 64x48", img.Bounds())
+			}
+			if mimeType != "image/png" {
+				return
+			}
+			// PNG 无损，像素应完全一致
+			for y := 0; y < 48; y++ {
+				for x := 0; x < 64; x++ {
+					if color.NRGBAModel.Convert(img.At(x, y)) != src.At(x, y) {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, img.At(x, y), src.At(x, y))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsInvalidData(t *testing.T) {
+	if _, err := Decode([]byte("not an image")); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("Decode error = %v, want ErrUnsupportedImage", err)
+	}
+}
+
+func TestDecodeRejectsTooManyPixels(t *testing.T) {
+	// 只修改 IHDR 中的尺寸即可触发检查，不需要真正的像素数据
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 10000)
+	binary.BigEndian.PutUint32(data[20:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	if _, err := Decode(data); !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("Decode error = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestStripMetadataJPEG(t *testing.T) {
+	plain := encode(t, gradient(40, 20), "image/jpeg")
+	comment := []byte{0xff, 0xfe, 0x00, 0x07, 'h', 'e', 'l', 'l', 'o'}
+	data := withJPEGSegments(plain, exifSegment(1), comment)
+
+	stripped, err := StripMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if !bytes.Equal(stripped, plain) {
+		t.Fatalf("stripped JPEG differs from the original without metadata (%d vs %d bytes)", len(stripped), len(plain))
+	}
+}
+
+func TestStripMetadataJPEGOrientation(t *testing.T) {
+	data := withJPEGSegments(encode(t, gradient(40, 20), "image/jpeg"), exifSegment(6))
+
+	img, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if img.Bounds().Dx() != 20 || img.Bounds().Dy() != 40 {
+		t.Fatalf("decoded size = %v, want 20x40 after rotation", img.Bounds())
+	}
+
+	// 带方向信息的 JPEG 旋转为正向后重新编码，不再包含 EXIF
+	stripped, err := StripMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("Exif\x00\x00")) {
+		t.Fatal("stripped JPEG still contains EXIF")
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("DecodeConfig: %v", err)
+	}
+	if cfg.Width != 20 || cfg.Height != 40 {
+		t.Fatalf("stripped size = %dx%d, want 20x40", cfg.Width, cfg.Height)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 2x1 的图片，左边红色、右边蓝色
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		topLeft     color.NRGBA
+	}{
+		{1, 2, 1, red},
+		{2, 2, 1, blue},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+	for _, tt := range tests {
+		img := applyOrientation(src, tt.orientation)
+		if img.Bounds().Dx() != tt.w || img.Bounds().Dy() != tt.h {
+			t.Fatalf("orientation %d: size = %v, want %dx%d", tt.orientation, img.Bounds(), tt.w, tt.h)
+		}
+		if got := color.NRGBAModel.Convert(img.At(0, 0)); got != tt.topLeft {
+			t.Fatalf("orientation %d: top-left = %v, want %v", tt.orientation, got, tt.topLeft)
+		}
+	}
+}
+
+func TestStripMetadataPNG(t *testing.T) {
+	plain := encode(t, gradient(16, 16), "image/png")
+
+	// 在 IHDR 之后插入 tEXt 数据块，CRC 不参与剥离逻辑
+	text := []byte{0, 0, 0, 5, 't', 'E', 'X', 't', 'a', 0, 'b', 'c', 'd', 0, 0, 0, 0}
+	ihdrEnd := 8 + 12 + 13
+	data := append(append(append([]byte{}, plain[:ihdrEnd]...), text...), plain[ihdrEnd:]...)
+
+	stripped, err := StripMetadata(data, "image/png")
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if !bytes.Equal(stripped, plain) {
+		t.Fatal("stripped PNG differs from the original without metadata")
+	}
+}
+
+func TestStripMetadataWebP(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x10 | 0x08 | 0x04 // 透明通道、EXIF、XMP
+	bitstream := riffChunk("VP8L", []byte{1, 2, 3})
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	body = append(body, bitstream...)
+	body = append(body, riffChunk("EXIF", []byte("exif!"))...)
+	body = append(body, riffChunk("XMP ", []byte("<x/>"))...)
+	data := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body)))
+	data = append(data, body...)
+
+	stripped, err := StripMetadata(data, "image/webp")
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+
+	wantVP8X := append([]byte{}, vp8x...)
+	wantVP8X[0] = 0x10
+	want := []byte("WEBP")
+	want = append(want, riffChunk("VP8X", wantVP8X)...)
+	want = append(want, bitstream...)
+	want = append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(want))), want...)
+	if !bytes.Equal(stripped, want) {
+		t.Fatalf("StripMetadata(webp) = %q, want %q", stripped, want)
+	}
+}
+
+func TestStripMetadataRejectsCorruptData(t *testing.T) {
+	for _, mimeType := range []string{"image/jpeg", "image/png", "image/webp"} {
+		if _, err := StripMetadata([]byte("garbage"), mimeType); !errors.Is(err, ErrUnsupportedImage) {
+			t.Fatalf("StripMetadata(%s) error = %v, want ErrUnsupportedImage", mimeType, err)
+		}
+	}
+}
+
+func TestThumbnailType(t *testing.T) {
+	opaque := gradient(4, 4)
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	tests := []struct {
+		img      image.Image
+		mimeType string
+		want     string
+	}{
+		{opaque, "image/jpeg", "image/jpeg"},
+		{opaque, "image/png", "image/png"},
+		{opaque, "image/gif", "image/png"},
+		{opaque, "image/webp", "image/webp"},
+		{transparent, "image/webp", "image/png"},
+	}
+	for _, tt := range tests {
+		if got := ThumbnailType(tt.img, tt.mimeType); got != tt.want {
+			t.Fatalf("ThumbnailType(%s) = %s, want %s", tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+// TestThumbnailSmallerThanSource 缩略图的文件应小于原图，使用仓库中的示例照片
+func TestThumbnailSmallerThanSource(t *testing.T) {
+	data, err := os.ReadFile("../uploads/photo_2022-06-10_06-45-53.jpg")
+	if err != nil {
+		t.Skip("sample photo not available:", err)
+	}
+	img, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	previous := 0
+	for _, size := range []int{128, 512} {
+		thumb := encode(t, Thumbnail(img, size), ThumbnailType(img, "image/jpeg"))
+		if len(thumb) >= len(data) {
+			t.Fatalf("thumb_%d is %d bytes, source is %d bytes", size, len(thumb), len(data))
+		}
+		if len(thumb) <= previous {
+			t.Fatalf("thumb_%d (%d bytes) is not larger than the previous size (%d bytes)", size, len(thumb), previous)
+		}
+		previous = len(thumb)
+	}
+}
@@ -0,0 +1,864 @@
+package imageproc
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// VP8（WebP 有损）相关常量
+const (
+	vp8MaxDimension = 1<<14 - 1
+	// vp8QuantIndex 量化索引（0-127），越大压缩率越高、画质越低，26 与 cwebp 默认质量 75 的量化参数相近
+	vp8QuantIndex = 26
+	// vp8FilterLevel 解码端环路滤波的强度（0-63），用于减轻块效应
+	vp8FilterLevel = 20
+	// maxFirstPartition 帧头中第一分区的长度只有 19 位
+	maxFirstPartition = 1<<19 - 1
+	maxCoeffLevel     = 2047
+)
+
+// 系数概率表的维度和使用的平面（RFC 6386 第 13 节）
+const (
+	numPlanes   = 4
+	numBands    = 8
+	numContexts = 3
+	numProbs    = 11
+
+	planeYAfterY2 = 0 // 亮度块的 AC 系数，DC 系数由 Y2 块编码
+	planeY2       = 1
+	planeUV       = 2
+)
+
+// 帧内预测模式，亮度只使用 16x16 预测
+const (
+	predDC = iota
+	predTM
+	predVE
+	predHE
+)
+
+var (
+	// coeffBands 扫描位置对应的概率分组，最后一项供末尾系数之后查表
+	coeffBands = [17]uint8{0, 1, 2, 3, 6, 4, 5, 6, 6, 6, 6, 6, 6, 6, 6, 7, 0}
+	// zigzag 扫描顺序对应的 4x4 块内位置
+	zigzag = [16]uint8{0, 1, 4, 8, 5, 2, 3, 6, 9, 12, 13, 10, 7, 11, 14, 15}
+	// catExtraProbs DCT_CAT3 至 DCT_CAT6 额外位的概率
+	catExtraProbs = [4][]uint8{
+		{173, 148, 140},
+		{176, 155, 140, 135},
+		{180, 157, 141, 134, 130},
+		{254, 254, 243, 230, 196, 177, 153, 140, 133, 130, 129},
+	}
+)
+
+// EncodeWebP 将图片编码为 WebP 有损格式（VP8 关键帧），带透明通道时另写未压缩的 ALPH 数据块
+// 亮度只使用 16x16 帧内预测，按统计结果更新系数概率，压缩率低于 libwebp，但不依赖 cgo
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || width > vp8MaxDimension || height > vp8MaxDimension {
+		return errors.New("webp: invalid image dimensions")
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	frame, err := newVP8Encoder(nrgba).encode()
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if alpha := alphaPlane(nrgba); alpha != nil {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10 // 带透明通道
+		putUint24(vp8x[4:], width-1)
+		putUint24(vp8x[7:], height-1)
+		body = appendChunk(body, "VP8X", vp8x)
+		body = appendChunk(body, "ALPH", append([]byte{0}, alpha...)) // 不压缩、不滤波
+	}
+	body = appendChunk(body, "VP8 ", frame)
+
+	header := make([]byte, 0, 12)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(4+len(body)))
+	header = append(header, "WEBP"...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// appendChunk 追加一个 RIFF 数据块，奇数长度补一个字节
+func appendChunk(dst []byte, fourCC string, payload []byte) []byte {
+	dst = append(dst, fourCC...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = append(dst, payload...)
+	if len(payload)%2 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// putUint24 以小端序写入 24 位整数
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// alphaPlane 返回逐像素的透明度，图片完全不透明时返回 nil
+func alphaPlane(img *image.NRGBA) []byte {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	alpha := make([]byte, 0, width*height)
+	opaque := true
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+width*4]
+		for x := 3; x < len(row); x += 4 {
+			alpha = append(alpha, row[x])
+			opaque = opaque && row[x] == 0xff
+		}
+	}
+	if opaque {
+		return nil
+	}
+	return alpha
+}
+
+// vp8Quant 各类系数的 DC、AC 量化步长和舍入偏移（以 1/256 为单位）
+type vp8Quant struct {
+	y1, y2, uv             [2]int32
+	y1Bias, y2Bias, uvBias [2]int32
+}
+
+// newVP8Quant 按解码器的规则由量化索引计算量化步长（RFC 6386 第 14.1 节）
+func newVP8Quant(index int) vp8Quant {
+	return vp8Quant{
+		y1:     [2]int32{int32(dcQuantTable[index]), int32(acQuantTable[index])},
+		y2:     [2]int32{int32(dcQuantTable[index]) * 2, max(int32(acQuantTable[index])*155/100, 8)},
+		uv:     [2]int32{int32(dcQuantTable[min(index, 117)]), int32(acQuantTable[index])},
+		y1Bias: [2]int32{96, 110},
+		y2Bias: [2]int32{96, 108},
+		uvBias: [2]int32{110, 115},
+	}
+}
+
+// quantize 量化一个系数，结果限制在系数可编码的范围内
+func quantize(coeff, step, bias int32) int16 {
+	abs := coeff
+	if abs < 0 {
+		abs = -abs
+	}
+	level := min((abs*256+step*bias)/(step*256), maxCoeffLevel)
+	if coeff < 0 {
+		return int16(-level)
+	}
+	return int16(level)
+}
+
+// dequantize 反量化，与解码器一样截断为 int16
+func dequantize(level int16, step int32) int32 {
+	return int32(int16(int32(level) * step))
+}
+
+// nzContext 宏块边缘各 4x4 块是否有非零系数，作为相邻块选择系数概率的上下文
+type nzContext struct {
+	y    [4]uint8
+	u, v [2]uint8
+	y2   uint8
+}
+
+// mbInfo 写入第一分区的宏块信息
+type mbInfo struct {
+	yMode, uvMode uint8
+	skip          bool
+}
+
+// vp8Encoder 编码一帧的状态
+type vp8Encoder struct {
+	mbw, mbh int
+	// src 和 rec 为 Y、U、V 三个平面的源图像和重建图像，宽高补齐到宏块的整数倍
+	// 后续宏块按解码器看到的重建像素预测，保证两端一致
+	src, rec    [3][]uint8
+	strides     [3]int
+	width       int
+	height      int
+	quant       vp8Quant
+	filterLevel int
+	mbs         []mbInfo
+	tokens      tokenWriter
+	topNz       []nzContext
+	leftNz      nzContext
+}
+
+// encode 编码 VP8 关键帧，返回 VP8 数据块的内容
+func (e *vp8Encoder) encode() ([]byte, error) {
+	for mby := 0; mby < e.mbh; mby++ {
+		e.leftNz = nzContext{}
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+
+	probs := e.tokens.chooseProbs()
+	first := e.firstPartition(&probs)
+	if len(first) > maxFirstPartition {
+		return nil, errors.New("webp: image is too large")
+	}
+	tokens := e.tokens.encode(&probs)
+
+	frame := make([]byte, 0, 10+len(first)+len(tokens))
+	tag := uint32(len(first))<<5 | 1<<4 // 关键帧、版本 0、显示
+	frame = append(frame, byte(tag), byte(tag>>8), byte(tag>>16), 0x9d, 0x01, 0x2a)
+	frame = binary.LittleEndian.AppendUint16(frame, uint16(e.width))
+	frame = binary.LittleEndian.AppendUint16(frame, uint16(e.height))
+	frame = append(frame, first...)
+	return append(frame, tokens...), nil
+}
+
+// newVP8Encoder 将图片转换为 YUV 4:2:0，超出图片的部分复制边缘像素
+// 颜色转换与 libwebp 相同，使用 BT.601 有限范围
+func newVP8Encoder(img *image.NRGBA) *vp8Encoder {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	e := &vp8Encoder{
+		mbw:         (width + 15) / 16,
+		mbh:         (height + 15) / 16,
+		width:       width,
+		height:      height,
+		quant:       newVP8Quant(vp8QuantIndex),
+		filterLevel: vp8FilterLevel,
+	}
+	e.mbs = make([]mbInfo, 0, e.mbw*e.mbh)
+	e.topNz = make([]nzContext, e.mbw)
+
+	yw, yh := e.mbw*16, e.mbh*16
+	e.strides = [3]int{yw, yw / 2, yw / 2}
+	for p, size := range [3]int{yw * yh, yw * yh / 4, yw * yh / 4} {
+		e.src[p] = make([]uint8, size)
+		e.rec[p] = make([]uint8, size)
+	}
+
+	rgb := func(x, y int) (int, int, int) {
+		i := min(y, height-1)*img.Stride + min(x, width-1)*4
+		return int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])
+	}
+	for y := 0; y < yh; y++ {
+		for x := 0; x < yw; x++ {
+			r, g, b := rgb(x, y)
+			e.src[0][y*yw+x] = uint8((16839*r + 33059*g + 6420*b + 1<<15 + 16<<16) >> 16)
+		}
+	}
+	for y := 0; y < yh/2; y++ {
+		for x := 0; x < yw/2; x++ {
+			var r, g, b int
+			for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb := rgb(2*x+d[0], 2*y+d[1])
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			i := y*e.strides[1] + x
+			e.src[1][i] = clipUV(-9719*r - 19081*g + 28800*b)
+			e.src[2][i] = clipUV(28800*r - 24116*g - 4684*b)
+		}
+	}
+	return e
+}
+
+// clipUV 由 2x2 像素之和计算的色度值舍入并截断到 0-255
+func clipUV(uv int) uint8 {
+	return clip8((uv + 1<<17 + 128<<18) >> 18)
+}
+
+// clip8 截断到 0-255
+func clip8(v int) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
+
+// encodeMacroblock 预测、变换和量化一个宏块，系数写入系数分区，重建结果写回 rec
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int) {
+	var (
+		y2Levels [16]int16
+		yLevels  [16][16]int16
+		uvLevels [2][4][16]int16
+	)
+	mb := mbInfo{
+		yMode:  e.encodeLuma(mbx, mby, &y2Levels, &yLevels),
+		uvMode: e.encodeChroma(mbx, mby, &uvLevels),
+	}
+
+	mb.skip = y2Levels == [16]int16{} && yLevels == [16][16]int16{} && uvLevels == [2][4][16]int16{}
+	e.mbs = append(e.mbs, mb)
+	top, left := &e.topNz[mbx], &e.leftNz
+	if mb.skip {
+		// 跳过的宏块没有系数，解码器将上下文全部置 0
+		*top, *left = nzContext{}, nzContext{}
+		return
+	}
+
+	nz := e.tokens.putCoeffs(planeY2, int(left.y2+top.y2), &y2Levels, 0)
+	left.y2, top.y2 = nz, nz
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			nz := e.tokens.putCoeffs(planeYAfterY2, int(left.y[y]+top.y[x]), &yLevels[y*4+x], 1)
+			left.y[y], top.y[x] = nz, nz
+		}
+	}
+	for p, ctx := range [2][2]*[2]uint8{{&left.u, &top.u}, {&left.v, &top.v}} {
+		l, t := ctx[0], ctx[1]
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				nz := e.tokens.putCoeffs(planeUV, int(l[y]+t[x]), &uvLevels[p][y*2+x], 0)
+				l[y], t[x] = nz, nz
+			}
+		}
+	}
+}
+
+// encodeLuma 选择亮度的 16x16 预测模式，量化 16 个 4x4 块的 AC 系数和 Y2 块，返回预测模式
+func (e *vp8Encoder) encodeLuma(mbx, mby int, y2Levels *[16]int16, levels *[16][16]int16) uint8 {
+	const n = 16
+	mode, pred := e.bestPrediction([]int{0}, mbx*n, mby*n, n)
+	src, rec, stride := e.src[0], e.rec[0], e.strides[0]
+	origin := mby*n*stride + mbx*n
+
+	var coeffs [16][16]int32
+	var dc [16]int32
+	for b := 0; b < 16; b++ {
+		offset := b/4*4*stride + b%4*4
+		forwardDCT(src[origin+offset:], stride, pred[0][b/4*4*n+b%4*4:], n, &coeffs[b])
+		dc[b] = coeffs[b][0]
+	}
+
+	// 各块的 DC 系数经 WHT 变换后作为 Y2 块单独编码
+	y2 := forwardWHT(&dc)
+	var y2Deq [16]int32
+	for i, z := range zigzag {
+		k := min(i, 1)
+		y2Levels[i] = quantize(y2[z], e.quant.y2[k], e.quant.y2Bias[k])
+		y2Deq[z] = dequantize(y2Levels[i], e.quant.y2[k])
+	}
+	dcDeq := inverseWHT(&y2Deq)
+
+	for b := 0; b < 16; b++ {
+		deq := [16]int32{dcDeq[b]}
+		for i := 1; i < 16; i++ {
+			z := zigzag[i]
+			levels[b][i] = quantize(coeffs[b][z], e.quant.y1[1], e.quant.y1Bias[1])
+			deq[z] = dequantize(levels[b][i], e.quant.y1[1])
+		}
+		offset := b/4*4*stride + b%4*4
+		inverseDCT(&deq, pred[0][b/4*4*n+b%4*4:], n, rec[origin+offset:], stride)
+	}
+	return mode
+}
+
+// encodeChroma 为 U、V 平面选择同一个 8x8 预测模式并量化系数，返回预测模式
+func (e *vp8Encoder) encodeChroma(mbx, mby int, levels *[2][4][16]int16) uint8 {
+	const n = 8
+	mode, pred := e.bestPrediction([]int{1, 2}, mbx*n, mby*n, n)
+	for p := 0; p < 2; p++ {
+		src, rec, stride := e.src[p+1], e.rec[p+1], e.strides[p+1]
+		origin := mby*n*stride + mbx*n
+		for b := 0; b < 4; b++ {
+			offset := b/2*4*stride + b%2*4
+			predOffset := b/2*4*n + b%2*4
+
+			var coeffs, deq [16]int32
+			forwardDCT(src[origin+offset:], stride, pred[p][predOffset:], n, &coeffs)
+			for i, z := range zigzag {
+				k := min(i, 1)
+				levels[p][b][i] = quantize(coeffs[z], e.quant.uv[k], e.quant.uvBias[k])
+				deq[z] = dequantize(levels[p][b][i], e.quant.uv[k])
+			}
+			inverseDCT(&deq, pred[p][predOffset:], n, rec[origin+offset:], stride)
+		}
+	}
+	return mode
+}
+
+// bestPrediction 在四种预测模式中选择与源图像误差平方和最小的一种，返回模式和各平面的预测值
+func (e *vp8Encoder) bestPrediction(planes []int, x0, y0, n int) (uint8, [2][]uint8) {
+	var best [2][]uint8
+	bestMode, bestErr := uint8(predDC), -1
+	for _, mode := range []uint8{predDC, predTM, predVE, predHE} {
+		var pred [2][]uint8
+		sse := 0
+		for i, p := range planes {
+			pred[i] = e.predict(p, mode, x0, y0, n)
+			src, stride := e.src[p], e.strides[p]
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					d := int(src[(y0+y)*stride+x0+x]) - int(pred[i][y*n+x])
+					sse += d * d
+				}
+			}
+		}
+		if bestErr < 0 || sse < bestErr {
+			bestMode, bestErr, best = mode, sse, pred
+		}
+	}
+	return bestMode, best
+}
+
+// predict 按解码器的规则由上边和左边的重建像素生成 n x n 的预测值
+// 第一行宏块的上边视为 127，第一列宏块的左边视为 129，DC 模式只使用存在的边
+func (e *vp8Encoder) predict(p int, mode uint8, x0, y0, n int) []uint8 {
+	rec, stride := e.rec[p], e.strides[p]
+	var top, left [16]int
+	topLeft := 129
+	if y0 == 0 {
+		topLeft = 127
+	} else if x0 > 0 {
+		topLeft = int(rec[(y0-1)*stride+x0-1])
+	}
+	for i := 0; i < n; i++ {
+		top[i], left[i] = 127, 129
+		if y0 > 0 {
+			top[i] = int(rec[(y0-1)*stride+x0+i])
+		}
+		if x0 > 0 {
+			left[i] = int(rec[(y0+i)*stride+x0-1])
+		}
+	}
+
+	pred := make([]uint8, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			switch mode {
+			case predTM:
+				pred[y*n+x] = clip8(left[y] + top[x] - topLeft)
+			case predVE:
+				pred[y*n+x] = uint8(top[x])
+			case predHE:
+				pred[y*n+x] = uint8(left[y])
+			}
+		}
+	}
+	if mode == predDC {
+		sum, count := 0, 0
+		for i := 0; i < n; i++ {
+			if y0 > 0 {
+				sum, count = sum+top[i], count+1
+			}
+			if x0 > 0 {
+				sum, count = sum+left[i], count+1
+			}
+		}
+		avg := uint8(128)
+		if count > 0 {
+			avg = uint8((sum + count/2) / count)
+		}
+		for i := range pred {
+			pred[i] = avg
+		}
+	}
+	return pred
+}
+
+// forwardDCT 计算 4x4 残差（源图像减预测值）的 DCT 系数，与 libwebp 的整数实现相同
+func forwardDCT(src []uint8, srcStride int, pred []uint8, predStride int, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		s, p := src[i*srcStride:], pred[i*predStride:]
+		d0 := int32(s[0]) - int32(p[0])
+		d1 := int32(s[1]) - int32(p[1])
+		d2 := int32(s[2]) - int32(p[2])
+		d3 := int32(s[3]) - int32(p[3])
+		a0, a1, a2, a3 := d0+d3, d1+d2, d1-d2, d0-d3
+		tmp[i*4+0] = (a0 + a1) * 8
+		tmp[i*4+1] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[i*4+2] = (a0 - a1) * 8
+		tmp[i*4+3] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[i] + tmp[12+i]
+		a1 := tmp[4+i] + tmp[8+i]
+		a2 := tmp[4+i] - tmp[8+i]
+		a3 := tmp[i] - tmp[12+i]
+		out[i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217 + a3*5352 + 12000) >> 16
+		if a3 != 0 {
+			out[4+i]++
+		}
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+}
+
+// inverseDCT 将反变换结果加到预测值上写入 dst，与解码器的整数实现逐位一致
+func inverseDCT(in *[16]int32, pred []uint8, predStride int, dst []uint8, dstStride int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := in[i] + in[8+i]
+		b := in[i] - in[8+i]
+		c := (in[4+i]*c2)>>16 - (in[12+i]*c1)>>16
+		d := (in[4+i]*c1)>>16 + (in[12+i]*c2)>>16
+		m[i] = [4]int32{a + d, b + c, b - c, a - d}
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		p, out := pred[j*predStride:], dst[j*dstStride:]
+		out[0] = clip8(int(p[0]) + int((a+d)>>3))
+		out[1] = clip8(int(p[1]) + int((b+c)>>3))
+		out[2] = clip8(int(p[2]) + int((b-c)>>3))
+		out[3] = clip8(int(p[3]) + int((a-d)>>3))
+	}
+}
+
+// forwardWHT 对 16 个块的 DC 系数做 Walsh-Hadamard 变换
+func forwardWHT(in *[16]int32) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[i*4+0] + in[i*4+2]
+		a1 := in[i*4+1] + in[i*4+3]
+		a2 := in[i*4+1] - in[i*4+3]
+		a3 := in[i*4+0] - in[i*4+2]
+		tmp[i*4+0] = a0 + a1
+		tmp[i*4+1] = a3 + a2
+		tmp[i*4+2] = a3 - a2
+		tmp[i*4+3] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[i] + tmp[8+i]
+		a1 := tmp[4+i] + tmp[12+i]
+		a2 := tmp[4+i] - tmp[12+i]
+		a3 := tmp[i] - tmp[8+i]
+		out[i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+	return out
+}
+
+// inverseWHT 还原各块的 DC 系数，与解码器的整数实现逐位一致
+func inverseWHT(in *[16]int32) [16]int32 {
+	var m, out [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[i] + in[12+i]
+		a1 := in[4+i] + in[8+i]
+		a2 := in[4+i] - in[8+i]
+		a3 := in[i] - in[12+i]
+		m[i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		dc := m[i*4] + 3
+		a0 := dc + m[i*4+3]
+		a1 := m[i*4+1] + m[i*4+2]
+		a2 := m[i*4+1] - m[i*4+2]
+		a3 := dc - m[i*4+3]
+		out[i*4+0] = int32(int16((a0 + a1) >> 3))
+		out[i*4+1] = int32(int16((a3 + a2) >> 3))
+		out[i*4+2] = int32(int16((a0 - a1) >> 3))
+		out[i*4+3] = int32(int16((a3 - a2) >> 3))
+	}
+	return out
+}
+
+// firstPartition 写入帧头和各宏块的预测模式
+func (e *vp8Encoder) firstPartition(probs *coeffProbs) []byte {
+	h := newBoolEncoder()
+	h.putBits(0, 2) // 色彩空间和像素截断方式
+	h.putBits(0, 1) // 不分段
+	h.putBits(0, 1) // 普通环路滤波
+	h.putBits(uint32(e.filterLevel), 6)
+	h.putBits(0, 3) // 锐度
+	h.putBits(0, 1) // 不按模式调整滤波强度
+	h.putBits(0, 2) // 只有一个系数分区
+	h.putBits(vp8QuantIndex, 7)
+	h.putBits(0, 5) // 各类系数不调整量化索引
+	h.putBits(0, 1) // 不保留本帧的概率
+	for i := range probs {
+		for j := range probs[i] {
+			for k := range probs[i][j] {
+				for l, prob := range probs[i][j][k] {
+					updated := prob != defaultCoeffProbs[i][j][k][l]
+					h.putBit(updated, coeffUpdateProbs[i][j][k][l])
+					if updated {
+						h.putBits(uint32(prob), 8)
+					}
+				}
+			}
+		}
+	}
+
+	skipped := 0
+	for _, mb := range e.mbs {
+		if mb.skip {
+			skipped++
+		}
+	}
+	skipProb := uint8(min(max((len(e.mbs)-skipped)*256/len(e.mbs), 1), 255))
+	h.putBits(1, 1)
+	h.putBits(uint32(skipProb), 8)
+
+	for _, mb := range e.mbs {
+		h.putBit(mb.skip, skipProb)
+		h.putBit(true, 145) // 亮度使用 16x16 预测
+		switch mb.yMode {
+		case predDC:
+			h.putBit(false, 156)
+			h.putBit(false, 163)
+		case predVE:
+			h.putBit(false, 156)
+			h.putBit(true, 163)
+		case predHE:
+			h.putBit(true, 156)
+			h.putBit(false, 128)
+		case predTM:
+			h.putBit(true, 156)
+			h.putBit(true, 128)
+		}
+		h.putBit(mb.uvMode != predDC, 142)
+		if mb.uvMode != predDC {
+			h.putBit(mb.uvMode != predVE, 114)
+			if mb.uvMode != predVE {
+				h.putBit(mb.uvMode == predTM, 183)
+			}
+		}
+	}
+	return h.flush()
+}
+
+// coeffProbs 系数概率表，概率均为该位取 0 的概率乘以 256
+type coeffProbs [numPlanes][numBands][numContexts][numProbs]uint8
+
+// token 系数分区中的一位，coeff 为 true 时使用系数概率表中的一项，否则使用固定概率 prob
+type token struct {
+	bit                    bool
+	coeff                  bool
+	prob                   uint8
+	plane, band, ctx, node uint8
+}
+
+// tokenWriter 先记录系数分区的所有位并统计分布，选定系数概率后再统一编码
+type tokenWriter struct {
+	tokens []token
+	counts [numPlanes][numBands][numContexts][numProbs][2]int
+}
+
+// putBit 记录使用固定概率的一位
+func (t *tokenWriter) putBit(bit bool, prob uint8) {
+	t.tokens = append(t.tokens, token{bit: bit, prob: prob})
+}
+
+// putCoeffBit 记录使用系数概率的一位
+func (t *tokenWriter) putCoeffBit(bit bool, plane, band, ctx, node int) {
+	t.tokens = append(t.tokens, token{bit: bit, coeff: true, plane: uint8(plane), band: uint8(band), ctx: uint8(ctx), node: uint8(node)})
+	if bit {
+		t.counts[plane][band][ctx][node][1]++
+	} else {
+		t.counts[plane][band][ctx][node][0]++
+	}
+}
+
+// putCoeffs 写入一个 4x4 块从 first 开始的量化系数（zigzag 顺序），返回是否有非零系数
+func (t *tokenWriter) putCoeffs(plane, ctx int, levels *[16]int16, first int) uint8 {
+	last := -1
+	for i := 15; i >= first; i-- {
+		if levels[i] != 0 {
+			last = i
+			break
+		}
+	}
+
+	band := int(coeffBands[first])
+	t.putCoeffBit(last >= 0, plane, band, ctx, 0)
+	if last < 0 {
+		return 0
+	}
+	for i := first; i <= last; i++ {
+		level := int(levels[i])
+		if level == 0 {
+			// 零系数之后不写块结束标志
+			t.putCoeffBit(false, plane, band, ctx, 1)
+			band, ctx = int(coeffBands[i+1]), 0
+			continue
+		}
+		t.putCoeffBit(true, plane, band, ctx, 1)
+		abs := max(level, -level)
+		t.putLevel(plane, band, ctx, abs)
+		t.putBit(level < 0, 128)
+
+		band, ctx = int(coeffBands[i+1]), 2
+		if abs == 1 {
+			ctx = 1
+		}
+		if i < 15 {
+			t.putCoeffBit(i < last, plane, band, ctx, 0)
+		}
+	}
+	return 1
+}
+
+// putLevel 按 RFC 6386 第 13.2 节的编码树写入系数的绝对值
+func (t *tokenWriter) putLevel(plane, band, ctx, v int) {
+	put := func(bit bool, node int) {
+		t.putCoeffBit(bit, plane, band, ctx, node)
+	}
+	put(v > 1, 2)
+	switch {
+	case v == 1:
+	case v <= 4:
+		put(false, 3)
+		put(v > 2, 4)
+		if v > 2 {
+			put(v == 4, 5)
+		}
+	case v <= 10:
+		put(true, 3)
+		put(false, 6)
+		put(v > 6, 7)
+		if v <= 6 {
+			t.putBit(v == 6, 159)
+		} else {
+			t.putBit((v-7)&2 != 0, 165)
+			t.putBit((v-7)&1 != 0, 145)
+		}
+	default:
+		put(true, 3)
+		put(true, 6)
+		cat := 3
+		switch {
+		case v < 19:
+			cat = 0
+		case v < 35:
+			cat = 1
+		case v < 67:
+			cat = 2
+		}
+		put(cat >= 2, 8)
+		put(cat&1 == 1, 9+cat>>1)
+		extra, probs := v-(3+8<<cat), catExtraProbs[cat]
+		for i, prob := range probs {
+			t.putBit(extra>>(len(probs)-1-i)&1 == 1, prob)
+		}
+	}
+}
+
+// chooseProbs 按统计到的分布为每项系数概率选择新值，节省的位数不足以抵消更新的开销时沿用默认值
+func (t *tokenWriter) chooseProbs() coeffProbs {
+	probs := coeffProbs(defaultCoeffProbs)
+	for i := range probs {
+		for j := range probs[i] {
+			for k := range probs[i][j] {
+				for l, old := range probs[i][j][k] {
+					n0, n1 := t.counts[i][j][k][l][0], t.counts[i][j][k][l][1]
+					if n0+n1 == 0 {
+						continue
+					}
+					prob := uint8(min(max((n0*256+(n0+n1)/2)/(n0+n1), 1), 255))
+					update := coeffUpdateProbs[i][j][k][l]
+					overhead := 8 + bitCost(0, 1, update) - bitCost(1, 0, update)
+					if prob != old && bitCost(n0, n1, old)-bitCost(n0, n1, prob) > overhead {
+						probs[i][j][k][l] = prob
+					}
+				}
+			}
+		}
+	}
+	return probs
+}
+
+// bitCost 以概率 prob 编码 n0 个 0 和 n1 个 1 所需的位数
+func bitCost(n0, n1 int, prob uint8) float64 {
+	p := float64(prob) / 256
+	return -float64(n0)*math.Log2(p) - float64(n1)*math.Log2(1-p)
+}
+
+// encode 使用选定的系数概率编码记录的所有位
+func (t *tokenWriter) encode(probs *coeffProbs) []byte {
+	enc := newBoolEncoder()
+	for _, tok := range t.tokens {
+		prob := tok.prob
+		if tok.coeff {
+			prob = probs[tok.plane][tok.band][tok.ctx][tok.node]
+		}
+		enc.putBit(tok.bit, prob)
+	}
+	return enc.flush()
+}
+
+// boolEncoder VP8 的布尔算术编码器（RFC 6386 第 7.3 节）
+type boolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rng: 255, bitCount: 24}
+}
+
+// putBit 写入一位，prob 为该位取 0 的概率乘以 256
+func (e *boolEncoder) putBit(bit bool, prob uint8) {
+	split := 1 + (e.rng-1)*uint32(prob)>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// putBits 以均等概率从高位到低位写入 n 位无符号整数
+func (e *boolEncoder) putBits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		e.putBit(v>>i&1 == 1, 128)
+	}
+}
+
+// carry 将进位传递到已输出的字节
+func (e *boolEncoder) carry() {
+	for i := len(e.buf) - 1; i >= 0; i-- {
+		e.buf[i]++
+		if e.buf[i] != 0 {
+			return
+		}
+	}
+}
+
+// flush 输出剩余的位并返回编码结果
+func (e *boolEncoder) flush() []byte {
+	c, v := e.bitCount, e.bottom
+	if v&(1<<(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= c & 7
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for i := 0; i < 4; i++ {
+		e.buf = append(e.buf, byte(v>>24))
+		v <<= 8
+	}
+	return e.buf
+}
@@ -0,0 +1,208 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// toNRGBA 将图片复制为从原点开始的 NRGBA
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			dst.Set(x, y, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// checkerboard 生成单像素黑白棋盘格，高频系数很大，覆盖系数编码的所有分类
+func checkerboard(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(255 * ((x + y) % 2))
+			img.Set(x, y, color.NRGBA{R: v, G: 255 - v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+// noise 生成带伪随机噪点的渐变图片
+func noise(w, h int) *image.NRGBA {
+	img := gradient(w, h)
+	seed := uint32(1)
+	for i := range img.Pix {
+		if i%4 == 3 {
+			continue
+		}
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] += uint8(seed >> 26)
+	}
+	return img
+}
+
+// lumaPSNR 计算解码结果与源图像亮度的峰值信噪比
+func lumaPSNR(t *testing.T, src *image.NRGBA, decoded image.Image) float64 {
+	t.Helper()
+	ycc, ok := decoded.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("decoded %T, want *image.YCbCr", decoded)
+	}
+	e := newVP8Encoder(src)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	var sse float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := float64(ycc.Y[y*ycc.YStride+x]) - float64(e.src[0][y*e.strides[0]+x])
+			sse += d * d
+		}
+	}
+	if sse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255*float64(w*h)/sse)
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		img     *image.NRGBA
+		minPSNR float64
+	}{
+		{"gradient", gradient(64, 48), 40},
+		{"odd size", gradient(37, 21), 40},
+		{"single pixel", gradient(1, 1), 40},
+		{"noise", noise(80, 60), 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encode(t, tt.img, "image/webp")
+			img, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if img.Bounds() != tt.img.Rect {
+				t.Fatalf("decoded bounds = %v, want %v", img.Bounds(), tt.img.Rect)
+			}
+			if psnr := lumaPSNR(t, tt.img, img); psnr < tt.minPSNR {
+				t.Fatalf("luma PSNR = %.2f dB, want at least %.0f dB", psnr, tt.minPSNR)
+			}
+		})
+	}
+}
+
+// TestVP8ReconstructionMatchesDecoder 关闭环路滤波时，解码结果应与编码器用于预测的重建图像逐像素一致
+func TestVP8ReconstructionMatchesDecoder(t *testing.T) {
+	for name, img := range map[string]*image.NRGBA{
+		"gradient":     gradient(50, 34),
+		"noise":        noise(96, 80),
+		"checkerboard": checkerboard(40, 24),
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := newVP8Encoder(img)
+			e.filterLevel = 0
+			frame, err := e.encode()
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			riff := appendChunk([]byte("RIFF\x00\x00\x00\x00WEBP"), "VP8 ", frame)
+			binary.LittleEndian.PutUint32(riff[4:], uint32(len(riff)-8))
+			decoded, err := webp.Decode(bytes.NewReader(riff))
+			if err != nil {
+				t.Fatalf("webp.Decode: %v", err)
+			}
+
+			ycc := decoded.(*image.YCbCr)
+			w, h := img.Rect.Dx(), img.Rect.Dy()
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					if got, want := ycc.Y[y*ycc.YStride+x], e.rec[0][y*e.strides[0]+x]; got != want {
+						t.Fatalf("Y(%d,%d) = %d, encoder reconstructed %d", x, y, got, want)
+					}
+				}
+			}
+			for y := 0; y < (h+1)/2; y++ {
+				for x := 0; x < (w+1)/2; x++ {
+					if ycc.Cb[y*ycc.CStride+x] != e.rec[1][y*e.strides[1]+x] || ycc.Cr[y*ycc.CStride+x] != e.rec[2][y*e.strides[2]+x] {
+						t.Fatalf("chroma (%d,%d) differs from the encoder reconstruction", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPAlpha(t *testing.T) {
+	src := gradient(30, 20)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			src.Pix[src.PixOffset(x, y)+3] = uint8(x * 255 / 29)
+		}
+	}
+
+	img, err := Decode(encode(t, src, "image/webp"))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	nycbcra, ok := img.(*image.NYCbCrA)
+	if !ok {
+		t.Fatalf("decoded %T, want *image.NYCbCrA", img)
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			if got, want := nycbcra.A[nycbcra.AOffset(x, y)], src.Pix[src.PixOffset(x, y)+3]; got != want {
+				t.Fatalf("alpha(%d,%d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeWebPRejectsInvalidDimensions(t *testing.T) {
+	for _, img := range []image.Image{
+		image.NewNRGBA(image.Rect(0, 0, 0, 10)),
+		image.NewGray(image.Rect(0, 0, vp8MaxDimension+1, 1)),
+	} {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, img); err == nil {
+			t.Fatalf("EncodeWebP(%v) succeeded, want an error", img.Bounds())
+		}
+	}
+}
+
+// TestWebPThumbnailSmallerThanJPEG WebP 缩略图应明显小于同尺寸的 JPEG 缩略图，使用仓库中的示例照片
+func TestWebPThumbnailSmallerThanJPEG(t *testing.T) {
+	data, err := os.ReadFile("../uploads/photo_2022-06-10_06-45-53.jpg")
+	if err != nil {
+		t.Skip("sample photo not available:", err)
+	}
+	img, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	for _, size := range []int{128, 512} {
+		thumb := toNRGBA(Thumbnail(img, size))
+		jpegData := encode(t, thumb, "image/jpeg")
+		webpData := encode(t, thumb, "image/webp")
+		if len(webpData) >= len(jpegData) {
+			t.Fatalf("thumb_%d: WebP is %d bytes, JPEG is %d bytes", size, len(webpData), len(jpegData))
+		}
+
+		decoded, err := Decode(webpData)
+		if err != nil {
+			t.Fatalf("thumb_%d: Decode: %v", size, err)
+		}
+		if psnr := lumaPSNR(t, thumb, decoded); psnr < 35 {
+			t.Fatalf("thumb_%d: luma PSNR = %.2f dB, want at least 35 dB", size, psnr)
+		}
+	}
+}
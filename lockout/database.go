@@ -0,0 +1,97 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginAttempt login_attempts 表中的一行，表结构由 migrations 创建
+type loginAttempt struct {
+	Subject       string `gorm:"primaryKey;size:191"`
+	Failures      int
+	LastFailureAt time.Time `gorm:"index"`
+	LockedUntil   *time.Time
+}
+
+func (loginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+func (a loginAttempt) state() State {
+	state := State{Failures: a.Failures, LastFailureAt: a.LastFailureAt}
+	if a.LockedUntil != nil {
+		state.LockedUntil = *a.LockedUntil
+	}
+	return state
+}
+
+// Database 数据库存储，多个副本共享同一份计数
+type Database struct {
+	db     *gorm.DB
+	window time.Duration
+}
+
+// NewDatabase 创建数据库存储
+func NewDatabase(db *gorm.DB, window time.Duration) *Database {
+	return &Database{db: db, window: window}
+}
+
+func (d *Database) Get(ctx context.Context, key string, now time.Time) (State, error) {
+	var attempt loginAttempt
+	err := d.db.WithContext(ctx).Where("subject = ?", key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+
+	state := attempt.state()
+	if state.expired(now, d.window) {
+		return State{}, nil
+	}
+	return state, nil
+}
+
+// RecordFailure 使用 upsert 在数据库中累加，多个副本同时写入同一个 key 时计数不会丢失
+// clause.Assignments 按列名排序生成 SQL，failures 在 last_failure_at 之前赋值，
+// MySQL 按顺序执行赋值，CASE 中读到的仍是旧的 last_failure_at
+func (d *Database) RecordFailure(ctx context.Context, key string, now time.Time) (State, error) {
+	db := d.db.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":        gorm.Expr("CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END", now.Add(-d.window)),
+			"last_failure_at": now,
+		}),
+	}).Create(&loginAttempt{Subject: key, Failures: 1, LastFailureAt: now}).Error
+	if err != nil {
+		return State{}, err
+	}
+
+	var attempt loginAttempt
+	if err := db.Where("subject = ?", key).First(&attempt).Error; err != nil {
+		return State{}, err
+	}
+	return attempt.state(), nil
+}
+
+func (d *Database) Lock(ctx context.Context, key string, until time.Time) error {
+	return d.db.WithContext(ctx).Model(&loginAttempt{}).
+		Where("subject = ?", key).
+		Update("locked_until", until).Error
+}
+
+func (d *Database) Reset(ctx context.Context, key string) error {
+	return d.db.WithContext(ctx).Where("subject = ?", key).Delete(&loginAttempt{}).Error
+}
+
+func (d *Database) Cleanup(ctx context.Context, now time.Time) error {
+	return d.db.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-d.window), now).
+		Delete(&loginAttempt{}).Error
+}
@@ -0,0 +1,62 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// State 一个计数对象（账号或 IP）的登录失败记录
+type State struct {
+	Failures      int       // 计数窗口内累计的失败次数
+	LastFailureAt time.Time // 最近一次失败的时间
+	LockedUntil   time.Time // 在此之前拒绝登录，零值表示未锁定
+}
+
+// Locked 判断在 now 时是否处于锁定中
+func (s State) Locked(now time.Time) bool {
+	return now.Before(s.LockedUntil)
+}
+
+// expired 计数窗口内没有新的失败且未处于锁定中，记录可以丢弃
+func (s State) expired(now time.Time, window time.Duration) bool {
+	return s.LastFailureAt.Before(now.Add(-window)) && !s.Locked(now)
+}
+
+// Store 登录失败记录的存储，key 由调用方区分类型，例如 "account:<email>"、"ip:<addr>"
+type Store interface {
+	// Get 返回 key 的记录，不存在或已过期时返回零值
+	Get(ctx context.Context, key string, now time.Time) (State, error)
+	// RecordFailure 原子地累加失败次数并返回累加后的记录，上次失败早于计数窗口时从 1 重新计数
+	RecordFailure(ctx context.Context, key string, now time.Time) (State, error)
+	// Lock 设置锁定的截止时间
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset 删除 key 的记录，用于登录成功和管理员解锁
+	Reset(ctx context.Context, key string) error
+	// Cleanup 删除已过期的记录
+	Cleanup(ctx context.Context, now time.Time) error
+}
+
+// Config 存储配置
+type Config struct {
+	Driver string        // memory（默认）或 database
+	Window time.Duration // 计数窗口，超过该时间没有新的失败时重新计数
+	DB     *gorm.DB      // database 驱动使用的连接
+}
+
+// New 根据配置创建存储
+func New(cfg Config) (Store, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return NewMemory(cfg.Window), nil
+	case "database":
+		if cfg.DB == nil {
+			return nil, fmt.Errorf("lockout store %q requires a database", cfg.Driver)
+		}
+		return NewDatabase(cfg.DB, cfg.Window), nil
+	default:
+		return nil, fmt.Errorf("unsupported lockout store %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,74 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory 内存存储，只在单个实例内计数，多副本部署时攻击者可以把请求分散到各个实例
+type Memory struct {
+	mu      sync.Mutex
+	window  time.Duration
+	records map[string]State
+}
+
+// NewMemory 创建内存存储
+func NewMemory(window time.Duration) *Memory {
+	return &Memory{window: window, records: make(map[string]State)}
+}
+
+func (m *Memory) Get(ctx context.Context, key string, now time.Time) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.records[key]
+	if !ok || state.expired(now, m.window) {
+		return State{}, nil
+	}
+	return state, nil
+}
+
+func (m *Memory) RecordFailure(ctx context.Context, key string, now time.Time) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.records[key]
+	if !ok || state.LastFailureAt.Before(now.Add(-m.window)) {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailureAt = now
+	m.records[key] = state
+	return state, nil
+}
+
+func (m *Memory) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.records[key]
+	state.LockedUntil = until
+	m.records[key] = state
+	return nil
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
+
+func (m *Memory) Cleanup(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, state := range m.records {
+		if state.expired(now, m.window) {
+			delete(m.records, key)
+		}
+	}
+	return nil
+}
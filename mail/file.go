@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File 将邮件保存为 .eml 文件，用于本地开发和测试，可以用邮件客户端直接打开
+type File struct {
+	dir  string
+	from string
+}
+
+// NewFile 创建文件发送器，目录不存在时自动创建
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create mail dir: %w", err)
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	data, err := buildMessage(f.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// 文件名按时间排序，随机后缀避免同一时刻的邮件互相覆盖
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+	return os.WriteFile(filepath.Join(f.dir, name), data, 0o600)
+}
@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"context"
+	"regexp"
+	"shared/logging"
+)
+
+// Log 将邮件的纯文本内容写入日志而不发送，用于本地开发
+// 链接中的查询参数（验证和重置令牌）会被隐去，需要打开链接时使用 file 驱动
+type Log struct{}
+
+// NewLog 创建日志发送器
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+	logging.FromContext(ctx).InfoContext(ctx, "Mail not sent (log driver)", "to", msg.To, "subject", msg.Subject, "body", redactLinks(msg.Text))
+	return nil
+}
+
+// linkQueryPattern 链接中的查询参数，令牌通过查询参数传递
+var linkQueryPattern = regexp.MustCompile(`(https?://[^\s?#]*)\?[^\s#]*`)
+
+// redactLinks 隐去正文中链接的查询参数，只保留地址和路径
+func redactLinks(text string) string {
+	return linkQueryPattern.ReplaceAllString(text, "$1?[REDACTED]")
+}
@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"shared/logging"
+	"strings"
+	"testing"
+)
+
+func TestLogRedactsLinks(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)))
+
+	msg := Message{
+		To:      "owner@example.com",
+		Subject: "Reset your password",
+		Text:    "Open http://localhost:8080/reset-password?token=eyJwIjoi.c2lnbmF0dXJl to continue.\nhttps://example.com/help#faq",
+	}
+	if err := NewLog().Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "eyJwIjoi") || strings.Contains(out, "c2lnbmF0dXJl") {
+		t.Fatalf("token was logged:\n%s", out)
+	}
+	for _, want := range []string{"http://localhost:8080/reset-password?[REDACTED]", "to continue", "https://example.com/help#faq", "owner@example.com"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log does not contain %q:\n%s", want, out)
+		}
+	}
+}
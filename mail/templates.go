@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// 邮件模板，每个模板文件定义 subject、text 和 html 三部分，正文同时包含中文和英文
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// emailTemplate 同一个文件分别按纯文本和 HTML 解析，html 部分的变量会按 HTML 转义
+// 每个文件单独解析，避免各文件中同名的 subject、text、html 互相覆盖
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = map[string]emailTemplate{
+	TemplateVerifyEmail:   mustParse(TemplateVerifyEmail),
+	TemplateResetPassword: mustParse(TemplateResetPassword),
+}
+
+// mustParse 解析 templates 目录下的模板文件，模板随程序一起编译，出错时 panic
+func mustParse(name string) emailTemplate {
+	file := "templates/" + name + ".tmpl"
+	return emailTemplate{
+		text: texttemplate.Must(texttemplate.ParseFS(templateFS, file)),
+		html: htmltemplate.Must(htmltemplate.ParseFS(templateFS, file)),
+	}
+}
+
+// Render 使用模板生成邮件内容，返回的 Message 未设置收件人
+func Render(name string, data interface{}) (Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mail template %q not found", name)
+	}
+
+	var subject, textBody, htmlBody strings.Builder
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&textBody, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&htmlBody, "html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
@@ -1,21 +1,177 @@
 package main
 
 import (
+	"context"
 	"go_core/config"
+	_ "go_core/migrations"
 	"go_core/models"
 	"go_core/routes"
+	"go_core/services"
+	"go_core/utils"
+	"go_core/validation"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"shared/lifecycle"
+	"shared/logging"
+	"shared/metrics"
+	"shared/migrate"
+	"shared/tracing"
+
+	"gorm.io/gorm"
 )
 
 func main() {
+	// 加载配置
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+
+	// 结构化日志，标准库 log 的输出也会经过它
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	migrate.SetLockName("go_core_schema_migrations")
+
+	// 数据库迁移子命令：go_core migrate up|down [n]|status|create <name>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		connect := func() (*gorm.DB, error) {
+			if err := config.InitDB(cfg.Database); err != nil {
+				return nil, err
+			}
+			return config.DB, nil
+		}
+		if err := migrate.Run(os.Args[2:], connect, os.Stdout); err != nil {
+			fatal("Migration command failed", err)
+		}
+		return
+	}
+
+	slog.Info("Configuration loaded", "config", cfg.String())
+
+	// 链路追踪，最先注册的清理函数最后执行，退出前导出剩余的 span
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config(cfg.Tracing))
+	if err != nil {
+		fatal("Failed to init tracing", err)
+	}
+	lifecycle.OnShutdown("tracing", shutdownTracing)
+
 	// 初始化数据库
-	config.InitDB()
+	if err := config.InitDB(cfg.Database); err != nil {
+		fatal("Failed to connect to database", err)
+	}
+	// 退出时最后关闭连接池，此前的清理函数仍可访问数据库
+	lifecycle.OnShutdown("database", func(ctx context.Context) error {
+		sqlDB, err := config.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+
+	// 记录查询耗时和连接池状态，通过 METRICS_ADDR 上的 /metrics 暴露；请求中的查询同时记录为 span
+	if err := metrics.InstrumentDB(config.DB, "go_core"); err != nil {
+		fatal("Failed to instrument database", err)
+	}
+	if err := tracing.InstrumentDB(config.DB); err != nil {
+		fatal("Failed to instrument database", err)
+	}
+
+	// 加载 JWT 签名密钥
+	if err := services.InitJWTKeys(cfg.JWT); err != nil {
+		fatal("Failed to load JWT keys", err)
+	}
+	services.SetBcryptCost(cfg.Auth.BcryptCost)
+	utils.SetCursorSecret(cfg.Auth.CursorSecret)
+
+	// 邮件发送，用于邮箱验证和密码重置
+	if err := services.InitMail(cfg.Mail, cfg.Auth); err != nil {
+		fatal("Failed to init mail", err)
+	}
 
-	// 自动迁移
-	models.Migrate()
+	// 初始化文件存储
+	if err := services.InitStorage(cfg.Storage); err != nil {
+		fatal("Failed to init storage", err)
+	}
+
+	// 执行数据库迁移，多个副本同时启动时由迁移锁保证只执行一次
+	if cfg.Database.AutoMigrate {
+		if err := migrate.Up(config.DB); err != nil {
+			fatal("Failed to migrate database", err)
+		}
+	} else if pending, err := migrate.Pending(config.DB); err != nil {
+		fatal("Failed to check migrations", err)
+	} else if len(pending) > 0 {
+		slog.Error("Database has pending migrations, run `migrate up` first", "pending", len(pending))
+		os.Exit(1)
+	}
+	services.RegisterReadinessChecks()
+
+	// 初始化内置角色和权限
+	if err := models.Seed(cfg.Auth.AdminEmail); err != nil {
+		fatal("Failed to seed roles", err)
+	}
+
+	// 启动图片缩略图生成
+	if err := services.StartImageWorkers(cfg.Image); err != nil {
+		fatal("Failed to start image workers", err)
+	}
+
+	// 断点续传配置，并定期清理过期的会话
+	services.InitUploads(cfg.Upload)
+	// 登录失败计数和锁定，并定期清理过期的记录
+	if err := services.InitLockout(cfg.Lockout); err != nil {
+		fatal("Failed to init login lockout", err)
+	}
+	if err := services.InitMFA(cfg.Auth); err != nil {
+		fatal("Failed to init two-factor authentication", err)
+	}
+	lifecycle.OnShutdown("background tasks", services.StopBackground)
+
+	// 接口限流的令牌桶存储
+	if err := services.InitRateLimit(cfg.RateLimit); err != nil {
+		fatal("Failed to init rate limit store", err)
+	}
+	lifecycle.OnShutdown("rate limit store", services.CloseRateLimit)
+
+	// 请求参数校验的自定义规则和中英文错误信息
+	if err := validation.Setup(); err != nil {
+		fatal("Failed to init validation", err)
+	}
+
+	// Prometheus 指标在单独的端口上提供，不经过业务路由
+	if cfg.Server.MetricsAddr != "" {
+		metricsServer, err := metrics.Serve(cfg.Server.MetricsAddr)
+		if err != nil {
+			fatal("Failed to start metrics server", err)
+		}
+		lifecycle.OnShutdown("metrics server", metricsServer.Shutdown)
+	}
 
 	// 初始化路由
-	r := routes.SetupRouter()
+	r, err := routes.SetupRouter(cfg)
+	if err != nil {
+		fatal("Failed to set up router", err)
+	}
+
+	// 运行服务，收到 SIGTERM 后等待进行中的请求完成再退出
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr(),
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	if err := lifecycle.Serve(srv, lifecycle.Config{ShutdownDelay: cfg.Server.ShutdownDelay, ShutdownTimeout: cfg.Server.ShutdownTimeout}); err != nil {
+		fatal("Server error", err)
+	}
+}
 
-	// 运行服务
-	r.Run(":8080")
+// fatal 记录错误日志并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
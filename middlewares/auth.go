@@ -2,19 +2,25 @@ package middlewares
 
 import (
 	"go_core/services"
-	"net/http"
+	"shared/apperr"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	errMissingToken       = apperr.Unauthorized("missing_token", "Authorization header is required")
+	errInvalidTokenFormat = apperr.Unauthorized("invalid_token", "Invalid token format")
+	errSessionRevoked     = apperr.Unauthorized("session_revoked", "Session has been revoked")
+)
+
 // AuthMiddleware 验证 JWT Token 是否有效
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从 Authorization header 中提取 token
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Authorization header is required"})
+			c.Error(errMissingToken)
 			c.Abort()
 			return
 		}
@@ -22,7 +28,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		// JWT 的格式通常是 "Bearer <token>"
 		parts := strings.Split(tokenString, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid token format"})
+			c.Error(errInvalidTokenFormat)
 			c.Abort()
 			return
 		}
@@ -31,7 +37,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 验证 token
 		claims, err := services.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		// 拒绝已退出登录或被撤销会话的 token
+		if !services.IsSessionActive(c.Request.Context(), claims.SessionID) {
+			c.Error(errSessionRevoked)
 			c.Abort()
 			return
 		}
@@ -43,3 +56,13 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// LogUser 请求日志中记录登录用户的邮箱，未登录的请求不记录
+func LogUser(c *gin.Context) []any {
+	if claims, ok := c.Get("user"); ok {
+		if claims, ok := claims.(*services.Claims); ok {
+			return []any{"user", claims.Email}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"go_core/services"
+	"shared/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errAuthenticationRequired = apperr.Unauthorized(apperr.CodeUnauthorized, "Authentication required")
+	errPermissionDenied       = apperr.Forbidden("permission_denied", "Permission denied")
+)
+
+// RequirePermission 要求当前用户的角色拥有指定权限，需放在 AuthMiddleware 之后
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		claims, ok := value.(*services.Claims)
+		if !exists || !ok {
+			c.Error(errAuthenticationRequired)
+			c.Abort()
+			return
+		}
+
+		allowed, err := services.HasPermission(c.Request.Context(), claims.Roles, permission)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.Error(errPermissionDenied)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"go_core/services"
+	sharedmw "shared/middlewares"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKey 根据配置返回计数身份的函数
+//   - ip：客户端 IP，只采信 TRUSTED_PROXIES 转发的 X-Forwarded-For
+//   - user：访问令牌中的用户，需放在 AuthMiddleware 之后，未登录时按 IP
+func RateLimitKey(by string) sharedmw.RateLimitKeyFunc {
+	if by == "user" {
+		return func(c *gin.Context) string {
+			value, _ := c.Get("user")
+			if claims, ok := value.(*services.Claims); ok {
+				return "user:" + strconv.FormatUint(uint64(claims.UserID), 10)
+			}
+			return sharedmw.ClientIPKey(c)
+		}
+	}
+	return sharedmw.ClientIPKey
+}
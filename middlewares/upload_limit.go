@@ -0,0 +1,17 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadLimit 限制路由允许上传的文件大小，上传接口通过 "upload_max_size" 读取该限制
+func UploadLimit(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// multipart 编码有额外开销，请求体上限在文件大小基础上预留 1MB
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+		c.Set("upload_max_size", maxSize)
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 初始表结构，与改用版本化迁移前 AutoMigrate 创建的表一致
+// 已有数据库执行时 AutoMigrate 会为已存在的表补上缺少的列和索引（不会删除或修改已有的列），
+// 表结构与当时的模型一致时不做改动，只记录版本号
+// 这里的结构体是当时模型的副本，之后修改 models 不影响本迁移，表结构的变更需要新增迁移
+func init() {
+	migrate.Register(migrate.Migration{
+		Version: 20261018090000,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			type permission struct {
+				gorm.Model
+				Name string `gorm:"size:64;uniqueIndex"`
+			}
+			type role struct {
+				gorm.Model
+				Name        string       `gorm:"size:64;uniqueIndex"`
+				Permissions []permission `gorm:"many2many:role_permissions;"`
+			}
+			type user struct {
+				gorm.Model
+				Name     string
+				Email    string
+				Password string
+				Roles    []role `gorm:"many2many:user_roles;"`
+			}
+			type refreshToken struct {
+				gorm.Model
+				UserID    uint   `gorm:"index"`
+				FamilyID  string `gorm:"size:64;index"`
+				TokenHash string `gorm:"size:64;uniqueIndex"`
+				ExpiresAt time.Time
+				UsedAt    *time.Time
+				RevokedAt *time.Time
+			}
+			type fileVariant struct {
+				ID         uint `gorm:"primarykey"`
+				CreatedAt  time.Time
+				FileID     uint   `gorm:"uniqueIndex:idx_file_variants_file_name"`
+				Name       string `gorm:"size:32;uniqueIndex:idx_file_variants_file_name"`
+				Width      int
+				Height     int
+				MimeType   string `gorm:"size:128"`
+				StoredName string `gorm:"size:160"`
+				Size       int64
+			}
+			type file struct {
+				gorm.Model
+				UserID           uint `gorm:"index"`
+				OriginalName     string
+				StoredName       string `gorm:"size:128;index"`
+				MimeType         string `gorm:"size:128"`
+				Size             int64
+				Checksum         string `gorm:"size:64"`
+				Width            int
+				Height           int
+				ProcessingStatus string `gorm:"size:16;index"`
+				Variants         []fileVariant
+			}
+			type product struct {
+				gorm.Model
+				Name    string
+				Price   float64
+				Version uint `gorm:"not null;default:1"`
+				ImageID *uint
+				Image   *file `gorm:"foreignKey:ImageID"`
+			}
+			type uploadSession struct {
+				ID        string `gorm:"primaryKey;size:32"`
+				UserID    uint   `gorm:"index"`
+				Filename  string
+				Length    int64
+				Offset    int64
+				Checksum  string `gorm:"size:64"`
+				FileID    *uint
+				ExpiresAt time.Time `gorm:"index"`
+				CreatedAt time.Time
+				UpdatedAt time.Time
+			}
+
+			err := tx.AutoMigrate(
+				&user{},
+				&product{},
+				&refreshToken{},
+				&role{},
+				&permission{},
+				&file{},
+				&fileVariant{},
+				&uploadSession{},
+			)
+			if err != nil {
+				return err
+			}
+
+			// MySQL 下为产品名称创建 FULLTEXT 索引，其他数据库搜索时回退为 LIKE
+			const indexName = "idx_products_name_fulltext"
+			if tx.Dialector.Name() != "mysql" || tx.Migrator().HasIndex(&product{}, indexName) {
+				return nil
+			}
+			return tx.Exec("CREATE FULLTEXT INDEX " + indexName + " ON products (name)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				"user_roles",
+				"role_permissions",
+				"upload_sessions",
+				"file_variants",
+				"products",
+				"files",
+				"refresh_tokens",
+				"users",
+				"roles",
+				"permissions",
+			)
+		},
+	})
+}
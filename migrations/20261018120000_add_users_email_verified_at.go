@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 记录用户验证邮箱的时间，已有用户为空，即视为未验证
+func init() {
+	type user struct {
+		EmailVerifiedAt *time.Time
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018120000,
+		Name:    "add_users_email_verified_at",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&user{}, "EmailVerifiedAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&user{}, "EmailVerifiedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			// GORM 在 SQLite 下通过重建表删除列，users 被 user_roles 的外键引用时会失败，改用 SQLite 3.35 起支持的 DROP COLUMN
+			if tx.Dialector.Name() == "sqlite" {
+				return tx.Exec("ALTER TABLE users DROP COLUMN email_verified_at").Error
+			}
+			return tx.Migrator().DropColumn(&user{}, "EmailVerifiedAt")
+		},
+	})
+}
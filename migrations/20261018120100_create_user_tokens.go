@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 邮箱验证和密码重置的一次性令牌
+func init() {
+	type userToken struct {
+		gorm.Model
+		UserID    uint   `gorm:"index"`
+		Purpose   string `gorm:"size:32"`
+		TokenHash string `gorm:"size:64;uniqueIndex"`
+		ExpiresAt time.Time
+		UsedAt    *time.Time
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018120100,
+		Name:    "create_user_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&userToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("user_tokens")
+		},
+	})
+}
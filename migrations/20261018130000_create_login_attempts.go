@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 登录失败计数，LOCKOUT_STORE=database 时多个副本共享
+func init() {
+	type loginAttempt struct {
+		Subject       string `gorm:"primaryKey;size:191"`
+		Failures      int
+		LastFailureAt time.Time `gorm:"index"`
+		LockedUntil   *time.Time
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018130000,
+		Name:    "create_login_attempts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&loginAttempt{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("login_attempts")
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 安全相关的审计事件，例如登录锁定和管理员解锁
+func init() {
+	type auditEvent struct {
+		ID        uint      `gorm:"primaryKey"`
+		CreatedAt time.Time `gorm:"index"`
+		Event     string    `gorm:"size:64;index"`
+		UserID    *uint     `gorm:"index"`
+		ActorID   *uint
+		Subject   string `gorm:"size:191"`
+		IP        string `gorm:"size:64"`
+		Details   string
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018130100,
+		Name:    "create_audit_events",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&auditEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("audit_events")
+		},
+	})
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证的 TOTP 密钥
+func init() {
+	type mfaSecret struct {
+		ID           uint `gorm:"primaryKey"`
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+		UserID       uint   `gorm:"uniqueIndex"`
+		Secret       string `gorm:"size:255"`
+		ConfirmedAt  *time.Time
+		LastUsedStep int64
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018140000,
+		Name:    "create_mfa_secrets",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&mfaSecret{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("mfa_secrets")
+		},
+	})
+}
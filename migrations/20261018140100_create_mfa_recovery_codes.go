@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 两步验证的一次性恢复码
+func init() {
+	type mfaRecoveryCode struct {
+		ID        uint `gorm:"primaryKey"`
+		CreatedAt time.Time
+		UserID    uint   `gorm:"index"`
+		CodeHash  string `gorm:"size:64;uniqueIndex"`
+		UsedAt    *time.Time
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018140100,
+		Name:    "create_mfa_recovery_codes",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&mfaRecoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("mfa_recovery_codes")
+		},
+	})
+}
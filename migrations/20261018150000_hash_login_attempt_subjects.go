@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+// 按账号计数的 subject 改为邮箱的 SHA-256，超过列长度的邮箱也能被锁定
+// 旧格式的计数不会再被读取，直接删除；回滚时同样删除新格式的计数，账号的失败次数重新开始
+func init() {
+	deleteAccountAttempts := func(tx *gorm.DB) error {
+		return tx.Exec("DELETE FROM login_attempts WHERE subject LIKE ?", "account:%").Error
+	}
+
+	migrate.Register(migrate.Migration{
+		Version: 20261018150000,
+		Name:    "hash_login_attempt_subjects",
+		Up:      deleteAccountAttempts,
+		Down:    deleteAccountAttempts,
+	})
+}
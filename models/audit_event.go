@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// 审计事件类型
+const (
+	AuditLoginLocked   = "login_locked"   // 登录失败次数过多被锁定
+	AuditLoginUnlocked = "login_unlocked" // 管理员解除锁定
+
+	AuditMFAEnabled                  = "mfa_enabled"                    // 用户完成两步验证的绑定
+	AuditMFADisabled                 = "mfa_disabled"                   // 用户关闭两步验证
+	AuditMFAReset                    = "mfa_reset"                      // 管理员重置两步验证
+	AuditMFARecoveryCodesRegenerated = "mfa_recovery_codes_regenerated" // 用户重新生成恢复码
+	AuditMFARecoveryCodeUsed         = "mfa_recovery_code_used"         // 使用恢复码登录或关闭两步验证
+)
+
+// AuditEvent 安全相关的审计事件，只追加不修改
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Event     string    `json:"event" gorm:"size:64;index"`
+	UserID    *uint     `json:"user_id" gorm:"index"` // 事件涉及的用户，按 IP 锁定或邮箱未注册时为空
+	ActorID   *uint     `json:"actor_id"`             // 执行操作的管理员，系统自动触发时为空
+	Subject   string    `json:"subject" gorm:"size:191"`
+	IP        string    `json:"ip" gorm:"size:64"`
+	Details   string    `json:"details"` // JSON 格式的附加信息
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 图片处理状态，非图片文件为空
+const (
+	ProcessingPending = "pending"
+	ProcessingDone    = "done"
+	ProcessingFailed  = "failed"
+)
+
+// File 上传的文件记录，文件内容按 SHA-256 命名存储，相同内容只保存一份
+type File struct {
+	gorm.Model
+	UserID           uint          `json:"user_id" gorm:"index"` // 上传者
+	OriginalName     string        `json:"original_name"`
+	StoredName       string        `json:"-" gorm:"size:128;index"` // 存储的文件名：<sha256>.<ext>
+	MimeType         string        `json:"mime_type" gorm:"size:128"`
+	Size             int64         `json:"size"`
+	Checksum         string        `json:"checksum" gorm:"size:64"`                          // 内容的 SHA-256
+	Width            int           `json:"width,omitempty"`                                  // 图片宽度，处理完成后写入
+	Height           int           `json:"height,omitempty"`                                 // 图片高度，处理完成后写入
+	ProcessingStatus string        `json:"processing_status,omitempty" gorm:"size:16;index"` // 图片缩略图的生成状态
+	Variants         []FileVariant `json:"variants,omitempty"`
+	URL              string        `json:"url" gorm:"-"`
+}
+
+// AfterFind 填充下载地址
+func (f *File) AfterFind(tx *gorm.DB) error {
+	f.URL = fmt.Sprintf("/api/files/%d", f.ID)
+	return nil
+}
+
+// AfterCreate 填充下载地址
+func (f *File) AfterCreate(tx *gorm.DB) error {
+	return f.AfterFind(tx)
+}
+
+// FileVariant 图片的衍生版本（缩略图、WebP 等），与原图使用同一存储后端
+type FileVariant struct {
+	ID         uint      `json:"-" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"-"`
+	FileID     uint      `json:"-" gorm:"uniqueIndex:idx_file_variants_file_name"`
+	Name       string    `json:"name" gorm:"size:32;uniqueIndex:idx_file_variants_file_name"` // 例如 thumb_512、thumb_512_webp
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	MimeType   string    `json:"mime_type" gorm:"size:128"`
+	StoredName string    `json:"-" gorm:"size:160"`
+	Size       int64     `json:"size"`
+	URL        string    `json:"url" gorm:"-"`
+}
+
+// AfterFind 填充下载地址
+func (v *FileVariant) AfterFind(tx *gorm.DB) error {
+	v.URL = fmt.Sprintf("/api/files/%d/variants/%s", v.FileID, v.Name)
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// MFASecret 用户的 TOTP 密钥，使用 AES-GCM 加密保存，每个用户最多一条
+// ConfirmedAt 为空表示已生成密钥但尚未用验证码确认，此时登录不要求两步验证
+type MFASecret struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex"`
+	Secret       string     `json:"-" gorm:"size:255"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `json:"-"` // 最近一次通过校验的时间步，拒绝不大于它的验证码，防止重放
+}
+
+// MFARecoveryCode 两步验证的恢复码，只保存以用户 ID 和服务端密钥计算的 HMAC-SHA256，每个恢复码只能使用一次
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"size:64;uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at"`
+}
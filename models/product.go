@@ -6,6 +6,9 @@ import (
 
 type Product struct {
 	gorm.Model
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	Name    string  `json:"name" binding:"required,max=255"`
+	Price   float64 `json:"price" binding:"gt=0"`
+	Version uint    `json:"version" gorm:"not null;default:1"` // 乐观锁版本号，每次更新加 1
+	ImageID *uint   `json:"image_id"`                          // 产品图片，引用上传的图片文件
+	Image   *File   `json:"image,omitempty" gorm:"foreignKey:ImageID"`
 }
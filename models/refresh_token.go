@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken 刷新令牌，只保存令牌的 SHA-256 哈希
+// 同一次登录轮换出来的令牌共享同一个 FamilyID（即会话 ID）
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"index"`
+	FamilyID  string     `json:"family_id" gorm:"size:64;index"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`    // 已被轮换的时间
+	RevokedAt *time.Time `json:"revoked_at"` // 被撤销的时间
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// 内置角色名称
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleUser   = "user"
+)
+
+// Role 角色，通过 role_permissions 关联权限
+type Role struct {
+	gorm.Model
+	Name        string       `json:"name" gorm:"size:64;uniqueIndex"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
+}
+
+// Permission 权限，命名形式为 "资源:操作"，例如 "products:write"
+type Permission struct {
+	gorm.Model
+	Name string `json:"name" gorm:"size:64;uniqueIndex"`
+}
+
+// defaultRolePermissions 内置角色及其默认权限
+var defaultRolePermissions = map[string][]string{
+	RoleAdmin:  {"products:write", "files:write", "files:read_all", "roles:manage", "users:unlock", "users:reset_mfa"},
+	RoleEditor: {"products:write", "files:write"},
+	RoleUser:   {},
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"go_core/config"
+)
+
+// Seed 初始化内置角色和权限，adminEmail 对应且已验证邮箱的用户会被授予管理员角色
+// 表结构由 migrations 中的版本化迁移创建，Seed 需在迁移之后调用，每次启动执行，可以重复执行
+func Seed(adminEmail string) error {
+	return seedRoles(adminEmail)
+}
+
+// seedRoles 创建内置角色和权限，并把 adminEmail 对应的用户设为管理员
+// 只授予已验证邮箱的账号，否则任何人都能抢先用该邮箱注册并在下次启动时获得管理员权限
+func seedRoles(adminEmail string) error {
+	for roleName, permissionNames := range defaultRolePermissions {
+		role := Role{Name: roleName}
+		if err := config.DB.Where(Role{Name: roleName}).FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		for _, permissionName := range permissionNames {
+			permission := Permission{Name: permissionName}
+			if err := config.DB.Where(Permission{Name: permissionName}).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+			// Append 对已存在的关联不会重复插入
+			if err := config.DB.Model(&role).Association("Permissions").Append(&permission); err != nil {
+				return err
+			}
+		}
+	}
+
+	if adminEmail == "" {
+		return nil
+	}
+
+	var admin User
+	if err := config.DB.Where("email = ? AND email_verified_at IS NOT NULL", adminEmail).First(&admin).Error; err != nil {
+		// 管理员账号尚未注册或尚未验证邮箱时跳过，验证后下次启动时授予
+		return nil
+	}
+	var adminRole Role
+	if err := config.DB.Where("name = ?", RoleAdmin).First(&adminRole).Error; err != nil {
+		return err
+	}
+	return config.DB.Model(&admin).Association("Roles").Append(&adminRole)
+}
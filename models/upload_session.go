@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// UploadSession 断点续传的上传会话，已接收的数据保存在本地临时文件中
+type UploadSession struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:32"` // 随机生成，同时作为临时文件名
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Filename  string    `json:"filename"`
+	Length    int64     `json:"length"`                  // 文件总大小
+	Offset    int64     `json:"offset"`                  // 已接收的字节数
+	Checksum  string    `json:"-" gorm:"size:64"`        // 客户端声明的完整文件 SHA-256，完成时校验
+	FileID    *uint     `json:"file_id"`                 // 上传完成后生成的文件记录
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"` // 过期后会话和临时文件会被清理
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,12 +1,17 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Email    string `json:"email" binding:"required,email,max=255,unique_email"`
+	Password string `json:"password" binding:"required,min=8,max_bytes=72"` // bcrypt 最多接受 72 字节，中文等多字节字符按字节计算
+	Roles    []Role `json:"-" gorm:"many2many:user_roles;"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // 为空表示邮箱未验证，只能通过验证链接设置
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 一次性令牌的用途
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeMFAChallenge  = "mfa_challenge"
+)
+
+// UserToken 邮箱验证、密码重置和两步验证挑战的一次性令牌，只保存令牌的 SHA-256 哈希
+type UserToken struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose" gorm:"size:32"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"` // 已使用或被新令牌取代的时间
+}
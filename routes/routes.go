@@ -1,27 +1,99 @@
 package routes
 
 import (
+	"go_core/config"
 	"go_core/controllers"
 	"go_core/middlewares"
+	"go_core/services"
+	sharedmw "shared/middlewares"
+	"shared/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
-	r.Use(middlewares.Logger())
+func SetupRouter(cfg *config.Config) (*gin.Engine, error) {
+	r := gin.New()
+	// 只采信可信代理转发的客户端地址，否则 X-Forwarded-For 可以伪造，绕过按 IP 的登录锁定
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+	r.Use(sharedmw.RequestID(), sharedmw.Tracing(), sharedmw.Logger(middlewares.LogUser), sharedmw.Metrics(), sharedmw.Recovery(), sharedmw.ErrorHandler())
+	r.NoRoute(sharedmw.NotFound("Route not found"))
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+	r.GET("/healthz", controllers.Liveness)
+	r.GET("/readyz", controllers.Readiness)
+	r.GET("/version", controllers.Version)
+
+	// 限流，每个路由组使用独立的令牌桶
+	limits := cfg.RateLimit
+	limiter := services.RateLimitStore()
+	rateLimit := func(group string, rate config.Rate, by string) gin.HandlerFunc {
+		limit := ratelimit.Limit{Burst: rate.Limit, Period: rate.Period}
+		return sharedmw.RateLimit(limiter, group, limit, middlewares.RateLimitKey(by))
+	}
+	uploadLimit := rateLimit("upload", limits.Upload, limits.UploadBy)
+
 	// Public routes
-	r.POST("/api/register", controllers.RegisterUser)
-	r.POST("/api/login", controllers.LoginUser)
+	public := r.Group("/api")
+	public.Use(rateLimit("auth", limits.Auth, limits.AuthBy))
+	{
+		public.POST("/register", controllers.RegisterUser)
+		public.POST("/login", controllers.LoginUser)
+		public.POST("/login/mfa", controllers.LoginMFA)
+		public.POST("/token/refresh", controllers.RefreshToken)
+		public.POST("/logout", controllers.Logout)
+		public.POST("/email/verify", controllers.VerifyEmail)
+		public.POST("/email/verify/resend", controllers.ResendVerificationEmail)
+		public.POST("/password/forgot", controllers.ForgotPassword)
+		public.POST("/password/reset", controllers.ResetPassword)
+	}
 
 	// Protected routes
 	protected := r.Group("/api")
-	protected.Use(middlewares.AuthMiddleware())
+	protected.Use(middlewares.AuthMiddleware(), rateLimit("api", limits.API, limits.APIBy))
 	{
 		protected.GET("/products", controllers.GetProducts)
-		protected.POST("/products", controllers.CreateProduct)
-		protected.POST("/upload", controllers.UploadFile)
+		protected.POST("/products", middlewares.RequirePermission("products:write"), controllers.CreateProduct)
+		protected.GET("/products/:id", controllers.GetProduct)
+		protected.PUT("/products/:id", middlewares.RequirePermission("products:write"), controllers.UpdateProduct)
+		protected.PATCH("/products/:id", middlewares.RequirePermission("products:write"), controllers.PatchProduct)
+		protected.DELETE("/products/:id", middlewares.RequirePermission("products:write"), controllers.DeleteProduct)
+		protected.POST("/products/:id/restore", middlewares.RequirePermission("products:write"), controllers.RestoreProduct)
+		protected.POST("/upload", middlewares.RequirePermission("files:write"), uploadLimit, middlewares.UploadLimit(int64(cfg.Upload.MaxSize)), controllers.UploadFile)
+		protected.GET("/files/:id", controllers.DownloadFile)
+		protected.GET("/files/:id/variants/:name", controllers.DownloadFileVariant)
+	}
+
+	// Two-factor authentication routes
+	mfa := protected.Group("/mfa")
+	{
+		mfa.GET("", controllers.GetMFAStatus)
+		mfa.POST("/totp/setup", controllers.SetupMFA)
+		mfa.POST("/totp/confirm", controllers.ConfirmMFA)
+		mfa.POST("/disable", controllers.DisableMFA)
+		mfa.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
+	}
+
+	// Resumable upload routes (tus protocol)
+	resumable := protected.Group("/upload/resumable")
+	resumable.Use(middlewares.RequirePermission("files:write"), middlewares.UploadLimit(int64(cfg.Upload.ResumableMaxSize)))
+	{
+		resumable.POST("", uploadLimit, controllers.CreateUpload)
+		resumable.HEAD("/:id", controllers.GetUploadOffset)
+		resumable.PATCH("/:id", controllers.UploadChunk)
+		resumable.DELETE("/:id", controllers.CancelUpload)
+	}
+
+	// Admin routes
+	admin := protected.Group("/admin")
+	admin.Use(middlewares.RequirePermission("roles:manage"))
+	{
+		admin.GET("/roles", controllers.ListRoles)
+		admin.POST("/users/:id/roles", controllers.GrantRole)
+		admin.DELETE("/users/:id/roles/:role", controllers.RevokeRole)
 	}
+	protected.POST("/admin/users/:id/unlock", middlewares.RequirePermission("users:unlock"), controllers.UnlockUser)
+	protected.POST("/admin/users/:id/mfa/reset", middlewares.RequirePermission("users:reset_mfa"), controllers.ResetUserMFA)
 
-	return r
+	return r, nil
 }
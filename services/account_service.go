@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_core/config"
+	"go_core/mail"
+	"go_core/models"
+	"net/url"
+	"shared/apperr"
+	"shared/logging"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAccountToken = apperr.BadRequest("invalid_account_token", "invalid, expired or already used token")
+	ErrEmailNotVerified    = apperr.Forbidden("email_not_verified", "Email address has not been verified")
+)
+
+// 邮箱验证和密码重置的设置，由 InitMail 根据配置设置
+var (
+	accountMailer        mail.Mailer = mail.NewLog()
+	mailLinkBaseURL                  = "http://localhost:8080"
+	verifyEmailTTL                   = 48 * time.Hour
+	passwordResetTTL                 = time.Hour
+	requireVerifiedEmail             = false
+	accountTokenSecret   []byte
+)
+
+// InitMail 根据配置初始化邮件发送器和邮箱验证、密码重置的设置
+// 令牌签名密钥为空时随机生成，只在当前进程内有效，多副本部署时必须配置
+func InitMail(mailCfg config.MailConfig, authCfg config.AuthConfig) error {
+	mailer, err := mail.New(mail.Config{
+		Driver:  mailCfg.Driver,
+		From:    mailCfg.From,
+		FileDir: mailCfg.FileDir,
+		SMTP: mail.SMTPConfig{
+			Host:     mailCfg.SMTP.Host,
+			Port:     mailCfg.SMTP.Port,
+			Username: mailCfg.SMTP.Username,
+			Password: mailCfg.SMTP.Password,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	accountMailer = mailer
+	mailLinkBaseURL = strings.TrimSuffix(mailCfg.LinkBaseURL, "/")
+	verifyEmailTTL = authCfg.VerifyEmailTTL
+	passwordResetTTL = authCfg.PasswordResetTTL
+	requireVerifiedEmail = authCfg.RequireVerifiedEmail
+
+	accountTokenSecret = []byte(authCfg.EmailTokenSecret)
+	if len(accountTokenSecret) == 0 {
+		accountTokenSecret = make([]byte, 32)
+		if _, err := rand.Read(accountTokenSecret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMailer 替换邮件发送器，测试时可传入自定义实现
+func SetMailer(mailer mail.Mailer) {
+	accountMailer = mailer
+}
+
+// CheckLoginAllowed 登录策略检查，开启 AUTH_REQUIRE_VERIFIED_EMAIL 时邮箱未验证的账号不能登录
+func CheckLoginAllowed(user *models.User) error {
+	if requireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// SendVerificationEmail 为用户签发邮箱验证令牌并发送验证邮件，邮箱已验证时不发送
+func SendVerificationEmail(ctx context.Context, user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	token, err := issueAccountToken(ctx, user.ID, models.TokenPurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return sendAccountMail(ctx, user, mail.TemplateVerifyEmail, "/verify-email", token, verifyEmailTTL)
+}
+
+// ResendVerificationEmail 按邮箱重新发送验证邮件
+// 邮箱未注册或已验证时同样返回成功，避免通过该接口探测邮箱是否已注册
+func ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return SendVerificationEmail(ctx, user)
+}
+
+// VerifyEmail 使用验证令牌将用户的邮箱标记为已验证
+func VerifyEmail(ctx context.Context, raw string) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := consumeAccountToken(tx, raw, models.TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		return markEmailVerified(tx, token.UserID)
+	})
+}
+
+// RequestPasswordReset 为邮箱对应的用户发送密码重置邮件
+// 邮箱未注册时同样返回成功，避免通过该接口探测邮箱是否已注册
+func RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	return sendAccountMail(ctx, user, mail.TemplateResetPassword, "/reset-password", token, passwordResetTTL)
+}
+
+// ResetPassword 使用重置令牌设置新密码，并撤销该用户的所有会话
+// 能收到重置邮件说明用户拥有该邮箱，邮箱未验证时一并标记为已验证
+func ResetPassword(ctx context.Context, raw, newPassword string) error {
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := consumeAccountToken(tx, raw, models.TokenPurposeResetPassword)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", token.UserID).Update("password", hash).Error; err != nil {
+			return err
+		}
+		if err := markEmailVerified(tx, token.UserID); err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", token.UserID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// markEmailVerified 记录邮箱验证时间，已验证时保留原来的时间
+func markEmailVerified(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", time.Now()).Error
+}
+
+// accountTokenPayload 令牌中签名的内容，过期时间和用途不需要查询数据库即可校验
+type accountTokenPayload struct {
+	Purpose   string `json:"p"`
+	UserID    uint   `json:"u"`
+	ExpiresAt int64  `json:"e"`
+	Nonce     string `json:"n"`
+}
+
+// issueAccountToken 签发一次性令牌，同一用户同一用途之前未使用的令牌随即失效
+// 令牌格式为 base64(payload).base64(HMAC-SHA256)，数据库中只保存整个令牌的哈希
+func issueAccountToken(ctx context.Context, userID uint, purpose string, ttl time.Duration) (string, error) {
+	nonce, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(ttl)
+	raw, err := encodeAccountToken(accountTokenPayload{Purpose: purpose, UserID: userID, ExpiresAt: expiresAt.Unix(), Nonce: nonce})
+	if err != nil {
+		return "", err
+	}
+
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			ExpiresAt: expiresAt,
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// findAccountToken 校验令牌的签名、用途和有效期，返回数据库中未使用的令牌记录
+func findAccountToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	if _, ok := decodeAccountToken(raw, purpose); !ok {
+		return nil, ErrInvalidAccountToken
+	}
+
+	var token models.UserToken
+	err := tx.Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAccountToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidAccountToken
+	}
+	return &token, nil
+}
+
+// consumeAccountToken 校验令牌并在事务中将其标记为已使用
+// 以 used_at 为空作为条件更新，并发使用同一个令牌时只有一个请求成功
+func consumeAccountToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	token, err := findAccountToken(tx, raw, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	result := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidAccountToken
+	}
+	return token, nil
+}
+
+// encodeAccountToken 将载荷编码为 base64(payload).base64(HMAC-SHA256)
+func encodeAccountToken(payload accountTokenPayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(signAccountToken(data)), nil
+}
+
+// decodeAccountToken 校验令牌的签名、用途和有效期，返回其中的载荷
+func decodeAccountToken(raw, purpose string) (*accountTokenPayload, bool) {
+	encoded, signature, ok := strings.Cut(raw, ".")
+	if !ok {
+		return nil, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, signAccountToken(data)) {
+		return nil, false
+	}
+	var payload accountTokenPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, false
+	}
+	if payload.Purpose != purpose || time.Now().Unix() >= payload.ExpiresAt {
+		return nil, false
+	}
+	return &payload, true
+}
+
+// signAccountToken 计算令牌内容的 HMAC-SHA256
+func signAccountToken(data []byte) []byte {
+	mac := hmac.New(sha256.New, accountTokenSecret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// accountMailData 邮件模板使用的数据，有效期同时提供中英文
+type accountMailData struct {
+	Name      string
+	Link      string
+	ExpiresZH string
+	ExpiresEN string
+}
+
+// sendAccountMail 生成带令牌链接的邮件并在后台发送
+// 请求不等待邮件服务器响应，也就不会因为耗时不同暴露邮箱是否已注册；发送失败只记录日志
+func sendAccountMail(ctx context.Context, user *models.User, template, path, token string, ttl time.Duration) error {
+	expiresZH, expiresEN := formatTTL(ttl)
+	msg, err := mail.Render(template, accountMailData{
+		Name:      user.Name,
+		Link:      mailLinkBaseURL + path + "?token=" + url.QueryEscape(token),
+		ExpiresZH: expiresZH,
+		ExpiresEN: expiresEN,
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = user.Email
+
+	logger := logging.FromContext(ctx)
+	mailer := accountMailer
+	goBackground(func(ctx context.Context) {
+		ctx = logging.WithLogger(ctx, logger)
+		if err := mailer.Send(ctx, msg); err != nil {
+			logger.Error("Failed to send mail", "template", template, "user_id", user.ID, "error", err)
+		}
+	})
+	return nil
+}
+
+// formatTTL 将有效期格式化为中文和英文，整小时显示为小时，否则显示为分钟
+func formatTTL(ttl time.Duration) (string, string) {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		hours := int(ttl / time.Hour)
+		return fmt.Sprintf("%d 小时", hours), plural(hours, "hour")
+	}
+	minutes := int((ttl + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("%d 分钟", minutes), plural(minutes, "minute")
+}
+
+// plural 英文的数量和单位
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_core/config"
+	"go_core/models"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupTestMail 使用 file 驱动把邮件保存到测试临时目录，返回该目录
+func setupTestMail(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := InitMail(
+		config.MailConfig{Driver: "file", From: "no-reply@example.com", LinkBaseURL: "https://app.example.com/", FileDir: dir},
+		config.AuthConfig{VerifyEmailTTL: 48 * time.Hour, PasswordResetTTL: time.Hour, EmailTokenSecret: "test-email-token-secret"},
+	)
+	if err != nil {
+		t.Fatalf("InitMail: %v", err)
+	}
+	return dir
+}
+
+var mailTokenPattern = regexp.MustCompile(`https://app\.example\.com(/[a-z-]+)\?token=(\S+)`)
+
+// lastMailToken 等待后台发送完成，从最近一封邮件的纯文本正文中取出链接的路径和令牌
+func lastMailToken(t *testing.T, dir, to string) (string, string) {
+	t.Helper()
+	backgroundTasks.Wait()
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(names) == 0 {
+		t.Fatalf("no mail was written: %v", err)
+	}
+	f, err := os.Open(names[len(names)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	msg, err := netmail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("parse mail: %v", err)
+	}
+	if got := msg.Header.Get("To"); got != to {
+		t.Fatalf("mail sent to %q, want %q", got, to)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// multipart 读取时自动解码 quoted-printable
+	part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := mailTokenPattern.FindStringSubmatch(string(text))
+	if match == nil {
+		t.Fatalf("no link in mail:\n%s", text)
+	}
+	token, err := url.QueryUnescape(match[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return match[1], token
+}
+
+func TestVerifyEmailToken(t *testing.T) {
+	setupTestDB(t)
+	dir := setupTestMail(t)
+	ctx := context.Background()
+
+	user := models.User{Name: "new", Email: "new@example.com", Password: "x"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := SendVerificationEmail(ctx, &user); err != nil {
+		t.Fatalf("SendVerificationEmail: %v", err)
+	}
+	path, token := lastMailToken(t, dir, user.Email)
+	if path != "/verify-email" {
+		t.Fatalf("link path = %q, want /verify-email", path)
+	}
+
+	// 用途不符的令牌不能使用，也不会被消耗
+	if err := ResetPassword(ctx, token, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("ResetPassword with a verification token error = %v, want ErrInvalidAccountToken", err)
+	}
+
+	if err := VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	config.DB.First(&user, user.ID)
+	if user.EmailVerifiedAt == nil {
+		t.Fatal("email was not marked as verified")
+	}
+
+	// 令牌只能使用一次
+	if err := VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("second VerifyEmail error = %v, want ErrInvalidAccountToken", err)
+	}
+
+	// 邮箱已验证时不再发送
+	if err := SendVerificationEmail(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	backgroundTasks.Wait()
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.eml")); len(names) != 1 {
+		t.Fatalf("%d mails were written, want 1", len(names))
+	}
+}
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	setupTestDB(t)
+	dir := setupTestMail(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	refreshToken, err := createRefreshToken(config.DB, user.ID, "family-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RequestPasswordReset(ctx, user.Email); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	path, token := lastMailToken(t, dir, user.Email)
+	if path != "/reset-password" {
+		t.Fatalf("link path = %q, want /reset-password", path)
+	}
+
+	if err := ResetPassword(ctx, token, "new-password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	var updated models.User
+	config.DB.First(&updated, user.ID)
+	if !CheckPassword(updated.Password, "new-password") || CheckPassword(updated.Password, "password123") {
+		t.Fatal("password was not changed")
+	}
+	if IsSessionActive(ctx, "family-1") {
+		t.Fatal("existing session is still active after the password reset")
+	}
+	if _, err := RotateRefreshToken(ctx, refreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("RotateRefreshToken error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	if err := ResetPassword(ctx, token, "another-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("reusing the reset token error = %v, want ErrInvalidAccountToken", err)
+	}
+}
+
+func TestPasswordResetForUnknownEmailSendsNothing(t *testing.T) {
+	setupTestDB(t)
+	dir := setupTestMail(t)
+
+	if err := RequestPasswordReset(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	backgroundTasks.Wait()
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.eml")); len(names) != 0 {
+		t.Fatalf("%d mails were written for an unknown email", len(names))
+	}
+}
+
+func TestAccountTokenExpiry(t *testing.T) {
+	setupTestDB(t)
+	setupTestMail(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	// 签名中的有效期已过
+	expired, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetPassword(ctx, expired, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("expired token error = %v, want ErrInvalidAccountToken", err)
+	}
+
+	// 数据库中的有效期已过
+	token, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Model(&models.UserToken{}).Where("token_hash = ?", hashToken(token)).Update("expires_at", time.Now().Add(-time.Minute))
+	if err := ResetPassword(ctx, token, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("token expired in the database error = %v, want ErrInvalidAccountToken", err)
+	}
+}
+
+func TestAccountTokenIsInvalidatedByANewerToken(t *testing.T) {
+	setupTestDB(t)
+	setupTestMail(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	first, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetPassword(ctx, first, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("older token error = %v, want ErrInvalidAccountToken", err)
+	}
+	if err := ResetPassword(ctx, second, "new-password"); err != nil {
+		t.Fatalf("newer token: %v", err)
+	}
+}
+
+func TestAccountTokenSignature(t *testing.T) {
+	setupTestDB(t)
+	setupTestMail(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	token, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature, _ := strings.Cut(token, ".")
+	tampered := []string{
+		"",
+		"not-a-token",
+		payload + "." + strings.Repeat("A", len(signature)),
+		payload + "x." + signature,
+	}
+	for _, raw := range tampered {
+		if err := ResetPassword(ctx, raw, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+			t.Fatalf("ResetPassword(%q) error = %v, want ErrInvalidAccountToken", raw, err)
+		}
+	}
+
+	// 更换签名密钥后之前签发的令牌失效
+	accountTokenSecret = []byte("another-secret")
+	if err := ResetPassword(ctx, token, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Fatalf("token signed with the old secret error = %v, want ErrInvalidAccountToken", err)
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"go_core/config"
+	"go_core/models"
+	"shared/logging"
+)
+
+// recordAudit 写入审计事件并输出日志
+// 写入失败只记录日志，不影响触发事件的请求
+func recordAudit(ctx context.Context, event models.AuditEvent, details map[string]interface{}) {
+	logger := logging.FromContext(ctx)
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			logger.Error("Failed to encode audit details", "event", event.Event, "error", err)
+		} else {
+			event.Details = string(data)
+		}
+	}
+
+	attrs := []interface{}{"event", event.Event, "subject", event.Subject, "ip", event.IP, "details", event.Details}
+	if event.UserID != nil {
+		attrs = append(attrs, "user_id", *event.UserID)
+	}
+	if event.ActorID != nil {
+		attrs = append(attrs, "actor_id", *event.ActorID)
+	}
+	logger.Info("Audit event", attrs...)
+	if err := config.DB.WithContext(ctx).Create(&event).Error; err != nil {
+		logger.Error("Failed to record audit event", "event", event.Event, "error", err)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// 后台任务（图片处理、过期上传清理）共享的生命周期，退出时由 StopBackground 取消并等待
+var (
+	backgroundCtx, cancelBackground = context.WithCancel(context.Background())
+	backgroundTasks                 sync.WaitGroup
+)
+
+// goBackground 启动一个后台协程，ctx 在 StopBackground 时被取消
+func goBackground(fn func(ctx context.Context)) {
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		fn(backgroundCtx)
+	}()
+}
+
+// StopBackground 通知后台任务退出并等待其完成，需在关闭数据库连接池之前调用
+// ctx 到期时不再等待，未处理完的图片保持 pending，下次启动时重新处理
+func StopBackground(ctx context.Context) error {
+	cancelBackground()
+
+	done := make(chan struct{})
+	go func() {
+		backgroundTasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
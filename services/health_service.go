@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_core/config"
+	"shared/lifecycle"
+	"shared/migrate"
+)
+
+// RegisterReadinessChecks 注册 /readyz 检查的依赖，需在 InitStorage 之后调用
+func RegisterReadinessChecks() {
+	lifecycle.OnReadiness("database", checkDatabase)
+	lifecycle.OnReadiness("storage", checkStorage)
+	lifecycle.OnReadiness("migrations", checkMigrations)
+}
+
+// checkDatabase 检查数据库连接是否可用
+func checkDatabase(ctx context.Context) error {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkStorage 检查文件存储后端是否可用
+func checkStorage(ctx context.Context) error {
+	if fileStorage == nil {
+		return errors.New("storage is not initialized")
+	}
+	return fileStorage.Ping(ctx)
+}
+
+// checkMigrations 检查数据库迁移是否已全部执行
+func checkMigrations(ctx context.Context) error {
+	return migrate.CheckApplied(config.DB.WithContext(ctx))
+}
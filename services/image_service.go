@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go_core/config"
+	"go_core/imageproc"
+	"go_core/models"
+	"image"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	imageJobQueueSize  = 256         // 待处理图片队列的长度
+	imageSweepInterval = time.Minute // 重新排队 pending 图片的间隔
+)
+
+// imageJobs 待生成缩略图的文件 ID，StartImageWorkers 调用前为 nil，此时上传的图片保持 pending
+var imageJobs chan uint
+
+// queuedImages 已在队列中或正在处理的文件 ID，避免定期扫描重复排队
+var queuedImages sync.Map
+
+// StartImageWorkers 启动后台图片处理协程，并重新排队上次未处理完的图片
+func StartImageWorkers(cfg config.ImageConfig) error {
+	// 缩略图尺寸去重并从小到大排序
+	seen := make(map[int]bool)
+	var sizes []int
+	for _, size := range cfg.ThumbnailSizes {
+		if !seen[size] {
+			seen[size] = true
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Ints(sizes)
+
+	imageJobs = make(chan uint, imageJobQueueSize)
+	for i := 0; i < cfg.Workers; i++ {
+		goBackground(func(ctx context.Context) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-imageJobs:
+					if err := processImage(ctx, id, sizes); err != nil && ctx.Err() == nil {
+						slog.Error("Failed to process image", "file_id", id, "error", err)
+					}
+					queuedImages.Delete(id)
+				}
+			}
+		})
+	}
+
+	if err := enqueuePendingImages(context.Background()); err != nil {
+		return err
+	}
+	startImageSweep()
+	return nil
+}
+
+// startImageSweep 启动后台协程，定期重新排队 pending 的图片，包括队列已满时未能排队的图片
+func startImageSweep() {
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(imageSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := enqueuePendingImages(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to enqueue pending images", "error", err)
+			}
+		}
+	})
+}
+
+// enqueuePendingImages 将 pending 的图片加入处理队列，每次最多取队列长度的数量，其余留给下次扫描
+func enqueuePendingImages(ctx context.Context) error {
+	var pending []uint
+	if err := config.DB.WithContext(ctx).Model(&models.File{}).
+		Where("processing_status = ?", models.ProcessingPending).
+		Order("id").Limit(imageJobQueueSize).
+		Pluck("id", &pending).Error; err != nil {
+		return err
+	}
+	for _, id := range pending {
+		if !enqueueImage(id) {
+			break
+		}
+	}
+	return nil
+}
+
+// enqueueImage 将图片加入处理队列，队列已满时直接返回 false，图片保持 pending，由定期扫描重新排队
+func enqueueImage(id uint) bool {
+	if imageJobs == nil {
+		return false
+	}
+	if _, queued := queuedImages.LoadOrStore(id, struct{}{}); queued {
+		return true
+	}
+	select {
+	case imageJobs <- id:
+		return true
+	default:
+		queuedImages.Delete(id)
+		return false
+	}
+}
+
+// processImage 为图片生成各尺寸的缩略图及其 WebP 版本，并记录处理结果
+func processImage(ctx context.Context, id uint, sizes []int) error {
+	var file models.File
+	if err := config.DB.First(&file, id).Error; err != nil {
+		return err
+	}
+	if file.ProcessingStatus != models.ProcessingPending {
+		return nil
+	}
+
+	width, height, err := generateVariants(ctx, &file, sizes)
+	if err != nil {
+		// 因退出而中断的图片保持 pending，下次启动时重新处理
+		if ctx.Err() == nil {
+			config.DB.Model(&file).Update("processing_status", models.ProcessingFailed)
+		}
+		return err
+	}
+
+	return config.DB.Model(&file).Updates(map[string]interface{}{
+		"width":             width,
+		"height":            height,
+		"processing_status": models.ProcessingDone,
+	}).Error
+}
+
+// generateVariants 解码原图并写入所有衍生版本，返回原图尺寸
+func generateVariants(ctx context.Context, file *models.File, sizes []int) (int, int, error) {
+	reader, err := fileStorage.Get(ctx, file.StoredName)
+	if err != nil {
+		return 0, 0, err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	img, err := imageproc.Decode(data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, size := range sizes {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		thumb := imageproc.Thumbnail(img, size)
+		name := fmt.Sprintf("thumb_%d", size)
+		thumbType := imageproc.ThumbnailType(thumb, file.MimeType)
+		if err := saveVariant(ctx, file, name, thumb, thumbType); err != nil {
+			return 0, 0, err
+		}
+		// WebP 版本使用有损编码，透明通道不压缩，带透明通道的缩略图只保留 PNG
+		if thumbType != "image/webp" && imageproc.Opaque(thumb) {
+			if err := saveVariant(ctx, file, name+"_webp", thumb, "image/webp"); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	bounds := img.Bounds()
+	return bounds.Dx(), bounds.Dy(), nil
+}
+
+// saveVariant 编码并保存一个衍生版本，存储名由原图哈希和版本名生成，重复处理时覆盖记录
+func saveVariant(ctx context.Context, file *models.File, name string, img image.Image, mimeType string) error {
+	var buf bytes.Buffer
+	if err := imageproc.Encode(&buf, img, mimeType); err != nil {
+		return err
+	}
+
+	storedName := file.Checksum + "_" + name + allowedUploadTypes[mimeType]
+	if err := fileStorage.Put(ctx, storedName, bytes.NewReader(buf.Bytes()), int64(buf.Len()), mimeType); err != nil {
+		return err
+	}
+
+	bounds := img.Bounds()
+	variant := models.FileVariant{FileID: file.ID, Name: name}
+	return config.DB.Where(variant).Assign(models.FileVariant{
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+		MimeType:   mimeType,
+		StoredName: storedName,
+		Size:       int64(buf.Len()),
+	}).FirstOrCreate(&variant).Error
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"go_core/config"
+	"go_core/imageproc"
+	"go_core/models"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testPhoto 生成带噪点的测试照片，编码为 JPEG
+func testPhoto(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			seed = seed*1664525 + 1013904223
+			noise := uint8(seed >> 27)
+			img.Set(x, y, color.NRGBA{R: uint8(x*200/w) + noise, G: uint8(y*200/h) + noise, B: 100 + noise, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := imageproc.Encode(&buf, img, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImageGeneratesThumbnails(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	photo := testPhoto(t, 1600, 1200)
+	file, err := saveContent(ctx, bytes.NewReader(photo), "photo.jpg", user.ID, UploadOptions{})
+	if err != nil {
+		t.Fatalf("saveContent: %v", err)
+	}
+	if file.ProcessingStatus != models.ProcessingPending {
+		t.Fatalf("processing status = %q, want pending", file.ProcessingStatus)
+	}
+
+	if err := processImage(ctx, file.ID, []int{128, 512}); err != nil {
+		t.Fatalf("processImage: %v", err)
+	}
+
+	var processed models.File
+	if err := config.DB.Preload("Variants").First(&processed, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if processed.ProcessingStatus != models.ProcessingDone || processed.Width != 1600 || processed.Height != 1200 {
+		t.Fatalf("processed = %s %dx%d, want done 1600x1200", processed.ProcessingStatus, processed.Width, processed.Height)
+	}
+	if len(processed.Variants) != 4 {
+		t.Fatalf("got %d variants, want 4", len(processed.Variants))
+	}
+
+	type wantVariant struct {
+		width, height int
+		mimeType, ext string
+	}
+	wantVariants := map[string]wantVariant{
+		"thumb_128":      {128, 96, "image/jpeg", ".jpg"},
+		"thumb_512":      {512, 384, "image/jpeg", ".jpg"},
+		"thumb_128_webp": {128, 96, "image/webp", ".webp"},
+		"thumb_512_webp": {512, 384, "image/webp", ".webp"},
+	}
+	sizes := make(map[string]int64)
+	for _, variant := range processed.Variants {
+		want, ok := wantVariants[variant.Name]
+		if !ok {
+			t.Fatalf("unexpected variant %q", variant.Name)
+		}
+		if variant.MimeType != want.mimeType || !strings.HasSuffix(variant.StoredName, want.ext) {
+			t.Fatalf("%s: type %s stored as %s, want %s", variant.Name, variant.MimeType, variant.StoredName, want.mimeType)
+		}
+		sizes[variant.Name] = variant.Size
+		if variant.Size >= processed.Size {
+			t.Fatalf("%s is %d bytes, not smaller than the %d byte source", variant.Name, variant.Size, processed.Size)
+		}
+
+		// 存储中的内容与记录一致，能解码为对应尺寸
+		reader, err := fileStorage.Get(ctx, variant.StoredName)
+		if err != nil {
+			t.Fatalf("%s: Get: %v", variant.Name, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(data)) != variant.Size {
+			t.Fatalf("%s: stored %d bytes, record says %d", variant.Name, len(data), variant.Size)
+		}
+		img, err := imageproc.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", variant.Name, err)
+		}
+		if img.Bounds().Dx() != want.width || img.Bounds().Dy() != want.height {
+			t.Fatalf("%s: size %v, want %dx%d", variant.Name, img.Bounds(), want.width, want.height)
+		}
+	}
+	// 有损 WebP 应小于同尺寸的 JPEG 缩略图
+	for _, name := range []string{"thumb_128", "thumb_512"} {
+		if sizes[name+"_webp"] >= sizes[name] {
+			t.Fatalf("%s_webp is %d bytes, JPEG is %d bytes", name, sizes[name+"_webp"], sizes[name])
+		}
+	}
+
+	// 已处理完成的图片再次处理时跳过
+	if err := processImage(ctx, file.ID, []int{64}); err != nil {
+		t.Fatalf("processImage again: %v", err)
+	}
+	var count int64
+	config.DB.Model(&models.FileVariant{}).Where("file_id = ?", file.ID).Count(&count)
+	if count != 4 {
+		t.Fatalf("variants after reprocessing = %d, want 4", count)
+	}
+}
+
+func TestProcessImageMarksUndecodableImageFailed(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	// 文件头是 PNG，但内容无法解码
+	data := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	if err := fileStorage.Put(ctx, "broken.png", bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	file := models.File{UserID: user.ID, StoredName: "broken.png", MimeType: "image/png", ProcessingStatus: models.ProcessingPending}
+	if err := config.DB.Create(&file).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processImage(ctx, file.ID, []int{128}); err == nil {
+		t.Fatal("processImage succeeded for an undecodable image")
+	}
+	config.DB.First(&file, file.ID)
+	if file.ProcessingStatus != models.ProcessingFailed {
+		t.Fatalf("processing status = %q, want failed", file.ProcessingStatus)
+	}
+}
+
+func TestEnqueueImageDoesNotBlockWhenQueueIsFull(t *testing.T) {
+	previous := imageJobs
+	imageJobs = make(chan uint, 1)
+	t.Cleanup(func() {
+		imageJobs = previous
+		queuedImages.Range(func(key, _ interface{}) bool {
+			queuedImages.Delete(key)
+			return true
+		})
+	})
+
+	if !enqueueImage(1) {
+		t.Fatal("first image was not queued")
+	}
+	if !enqueueImage(1) {
+		t.Fatal("an image already in the queue should be reported as queued")
+	}
+	if enqueueImage(2) {
+		t.Fatal("image was queued although the queue is full")
+	}
+	// 未能排队的图片不记为已排队，之后的扫描可以重新排队
+	<-imageJobs
+	queuedImages.Delete(uint(1))
+	if !enqueueImage(2) {
+		t.Fatal("dropped image could not be queued again")
+	}
+}
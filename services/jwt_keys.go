@@ -0,0 +1,265 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_core/config"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwtKey 签名和验证 JWT 使用的一把密钥
+type jwtKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Sign    interface{} // 签名用的私钥（HMAC 为共享密钥），仅签名密钥需要
+	Verify  interface{} // 验证用的公钥（HMAC 为共享密钥）
+	Private bool        // 是否为对称密钥，对称密钥不会出现在 JWKS 中
+}
+
+// jwtKeySet 当前的签名密钥以及所有可用于验证的密钥（按 kid 索引）
+type jwtKeySet struct {
+	signing *jwtKey
+	verify  map[string]*jwtKey
+}
+
+// keySet 当前使用的密钥，由 InitJWTKeys 加载
+var keySet *jwtKeySet
+
+// InitJWTKeys 加载 JWT 密钥并设置令牌有效期，启动时调用以便尽早发现配置错误
+func InitJWTKeys(cfg config.JWTConfig) error {
+	set, err := loadKeySet(cfg)
+	if err != nil {
+		return err
+	}
+	keySet = set
+	accessTokenTTL = cfg.AccessTokenTTL
+	refreshTokenTTL = cfg.RefreshTokenTTL
+	tokenIssuer = cfg.Issuer
+	return nil
+}
+
+// currentKeySet 返回已加载的密钥
+func currentKeySet() (*jwtKeySet, error) {
+	if keySet == nil {
+		return nil, errors.New("JWT keys are not initialized")
+	}
+	return keySet, nil
+}
+
+// loadKeySet 根据配置加载密钥
+//
+//	SigningAlg      HS256（默认）、RS256 或 EdDSA
+//	Secret          HS256 的共享密钥
+//	PrivateKeyFile  RS256/EdDSA 的 PEM 私钥文件
+//	KeyID           签名密钥的 kid，默认取公钥指纹
+//	VerifyKeys      轮换期间仍然有效的旧公钥，每项格式为 "kid=path.pem"
+func loadKeySet(cfg config.JWTConfig) (*jwtKeySet, error) {
+	set := &jwtKeySet{verify: make(map[string]*jwtKey)}
+
+	alg := cfg.SigningAlg
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+
+	var signing *jwtKey
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := cfg.Secret
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		signing = &jwtKey{
+			ID:      cfg.KeyID,
+			Method:  jwt.SigningMethodHS256,
+			Sign:    []byte(secret),
+			Verify:  []byte(secret),
+			Private: true,
+		}
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		key, err := loadPrivateKey(alg, cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.KeyID != "" {
+			key.ID = cfg.KeyID
+		}
+		signing = key
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q", alg)
+	}
+
+	set.signing = signing
+	if signing.ID != "" {
+		set.verify[signing.ID] = signing
+	}
+
+	// 加载轮换期间的旧验证公钥
+	for _, entry := range cfg.VerifyKeys {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT_VERIFY_KEYS entry %q", entry)
+		}
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = kid
+		set.verify[kid] = key
+	}
+
+	return set, nil
+}
+
+// loadPrivateKey 从 PEM 文件读取 RSA 或 Ed25519 私钥
+func loadPrivateKey(alg, path string) (*jwtKey, error) {
+	if path == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", alg)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT private key: %w", err)
+	}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse RSA private key: %w", err)
+		}
+		return &jwtKey{
+			ID:     keyThumbprint(&private.PublicKey),
+			Method: jwt.SigningMethodRS256,
+			Sign:   private,
+			Verify: &private.PublicKey,
+		}, nil
+	default:
+		private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse Ed25519 private key: %w", err)
+		}
+		public := private.(ed25519.PrivateKey).Public()
+		return &jwtKey{
+			ID:     keyThumbprint(public),
+			Method: jwt.SigningMethodEdDSA,
+			Sign:   private,
+			Verify: public,
+		}, nil
+	}
+}
+
+// loadPublicKey 从 PEM 文件读取 RSA 或 Ed25519 公钥，自动识别类型
+func loadPublicKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT public key: %w", err)
+	}
+
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{Method: jwt.SigningMethodRS256, Verify: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{Method: jwt.SigningMethodEdDSA, Verify: public}, nil
+	}
+	return nil, fmt.Errorf("unsupported public key in %s", path)
+}
+
+// keyThumbprint 用公钥 DER 编码的 SHA-256 前 16 位作为默认 kid
+func keyThumbprint(public interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+// signToken 使用当前签名密钥签发 Token，并在头部写入 kid
+func signToken(claims jwt.Claims) (string, error) {
+	set, err := currentKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(set.signing.Method, claims)
+	if set.signing.ID != "" {
+		token.Header["kid"] = set.signing.ID
+	}
+	return token.SignedString(set.signing.Sign)
+}
+
+// verificationKey 根据 Token 头部的 kid 和 alg 选择验证密钥
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	set, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	key := set.signing
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		if key, ok = set.verify[kid]; !ok {
+			return nil, errors.New("unknown signing key")
+		}
+	}
+
+	// 算法必须与密钥匹配，防止算法混淆攻击
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Verify, nil
+}
+
+// JWK 是 JSON Web Key 的公开部分
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS 返回所有非对称验证公钥，供其他服务验证 go_core 签发的 Token
+func JWKS() ([]JWK, error) {
+	set, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []JWK{}
+	for kid, key := range set.verify {
+		if key.Private {
+			continue
+		}
+		jwk := JWK{Kid: kid, Alg: key.Method.Alg(), Use: "sig"}
+		switch public := key.Verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"go_core/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// testKeys 测试用的 RSA 和 Ed25519 密钥对及其 PEM 文件
+type testKeys struct {
+	rsa, oldRSA      *rsa.PrivateKey
+	ed               ed25519.PrivateKey
+	rsaFile, edFile  string // 私钥
+	oldRSAPub, edPub string // 公钥
+	garbage          string // 不是 PEM 的文件
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	dir := t.TempDir()
+	k := &testKeys{}
+	var err error
+	if k.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if k.oldRSA, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if _, k.ed, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	pkix := func(key interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	k.rsaFile = write("rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k.rsa))
+	k.edFile = write("ed.pem", "PRIVATE KEY", pkcs8(k.ed))
+	k.oldRSAPub = write("old.pub.pem", "PUBLIC KEY", pkix(&k.oldRSA.PublicKey))
+	k.edPub = write("ed.pub.pem", "PUBLIC KEY", pkix(k.ed.Public()))
+	k.garbage = filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(k.garbage, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// useKeySet 加载密钥作为当前密钥，测试结束后恢复
+func useKeySet(t *testing.T, cfg config.JWTConfig) *jwtKeySet {
+	t.Helper()
+	set, err := loadKeySet(cfg)
+	if err != nil {
+		t.Fatalf("loadKeySet: %v", err)
+	}
+	saved := keySet
+	keySet = set
+	t.Cleanup(func() { keySet = saved })
+	return set
+}
+
+// signWith 用指定的算法、密钥和 kid 签发测试 Token
+func signWith(t *testing.T, method jwt.SigningMethod, key interface{}, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign %s: %v", method.Alg(), err)
+	}
+	return signed
+}
+
+// verify 按当前密钥验证 Token
+func verify(raw string) error {
+	_, err := jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, verificationKey)
+	return err
+}
+
+func TestLoadKeySet(t *testing.T) {
+	k := newTestKeys(t)
+	rsaKid := keyThumbprint(&k.rsa.PublicKey)
+	edKid := keyThumbprint(k.ed.Public())
+
+	tests := []struct {
+		name       string
+		cfg        config.JWTConfig
+		wantErr    string
+		wantAlg    string
+		wantKid    string
+		wantVerify []string // verify 中的 kid
+	}{
+		{name: "default alg is HS256", cfg: config.JWTConfig{Secret: "s"}, wantAlg: "HS256"},
+		{name: "HS256 with kid", cfg: config.JWTConfig{SigningAlg: "HS256", Secret: "s", KeyID: "hs"}, wantAlg: "HS256", wantKid: "hs", wantVerify: []string{"hs"}},
+		{name: "HS256 without secret", cfg: config.JWTConfig{SigningAlg: "HS256"}, wantErr: "JWT_SECRET is required"},
+		{name: "RS256 PKCS1 PEM", cfg: config.JWTConfig{SigningAlg: "RS256", PrivateKeyFile: k.rsaFile}, wantAlg: "RS256", wantKid: rsaKid, wantVerify: []string{rsaKid}},
+		{name: "RS256 with configured kid", cfg: config.JWTConfig{SigningAlg: "RS256", PrivateKeyFile: k.rsaFile, KeyID: "2024"}, wantAlg: "RS256", wantKid: "2024", wantVerify: []string{"2024"}},
+		{name: "EdDSA PKCS8 PEM", cfg: config.JWTConfig{SigningAlg: "EdDSA", PrivateKeyFile: k.edFile}, wantAlg: "EdDSA", wantKid: edKid, wantVerify: []string{edKid}},
+		{name: "RS256 without key file", cfg: config.JWTConfig{SigningAlg: "RS256"}, wantErr: "JWT_PRIVATE_KEY_FILE is required"},
+		{name: "RS256 with Ed25519 key", cfg: config.JWTConfig{SigningAlg: "RS256", PrivateKeyFile: k.edFile}, wantErr: "parse RSA private key"},
+		{name: "EdDSA with RSA key", cfg: config.JWTConfig{SigningAlg: "EdDSA", PrivateKeyFile: k.rsaFile}, wantErr: "parse Ed25519 private key"},
+		{name: "missing key file", cfg: config.JWTConfig{SigningAlg: "RS256", PrivateKeyFile: filepath.Join(t.TempDir(), "none.pem")}, wantErr: "read JWT private key"},
+		{name: "unsupported alg", cfg: config.JWTConfig{SigningAlg: "HS512", Secret: "s"}, wantErr: "unsupported JWT_SIGNING_ALG"},
+		{
+			name:       "verify keys",
+			cfg:        config.JWTConfig{SigningAlg: "RS256", PrivateKeyFile: k.rsaFile, VerifyKeys: []string{"old=" + k.oldRSAPub, " ed=" + k.edPub + " ", ""}},
+			wantAlg:    "RS256",
+			wantKid:    rsaKid,
+			wantVerify: []string{rsaKid, "old", "ed"},
+		},
+		{name: "verify key without kid", cfg: config.JWTConfig{Secret: "s", VerifyKeys: []string{k.oldRSAPub}}, wantErr: "invalid JWT_VERIFY_KEYS entry"},
+		{name: "verify key without path", cfg: config.JWTConfig{Secret: "s", VerifyKeys: []string{"old="}}, wantErr: "invalid JWT_VERIFY_KEYS entry"},
+		{name: "verify key is not a public key", cfg: config.JWTConfig{Secret: "s", VerifyKeys: []string{"bad=" + k.garbage}}, wantErr: "unsupported public key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := loadKeySet(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadKeySet error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadKeySet: %v", err)
+			}
+			if set.signing.Method.Alg() != tt.wantAlg || set.signing.ID != tt.wantKid {
+				t.Fatalf("signing key = %s/%q, want %s/%q", set.signing.Method.Alg(), set.signing.ID, tt.wantAlg, tt.wantKid)
+			}
+			if len(set.verify) != len(tt.wantVerify) {
+				t.Fatalf("verify keys = %d, want %v", len(set.verify), tt.wantVerify)
+			}
+			for _, kid := range tt.wantVerify {
+				if set.verify[kid] == nil {
+					t.Fatalf("verify key %q is missing", kid)
+				}
+			}
+		})
+	}
+}
+
+func TestSignAndVerifyEachAlg(t *testing.T) {
+	k := newTestKeys(t)
+	for _, cfg := range []config.JWTConfig{
+		{SigningAlg: "HS256", Secret: "secret"},
+		{SigningAlg: "HS256", Secret: "secret", KeyID: "hs"},
+		{SigningAlg: "RS256", PrivateKeyFile: k.rsaFile},
+		{SigningAlg: "EdDSA", PrivateKeyFile: k.edFile},
+	} {
+		t.Run(cfg.SigningAlg+"/"+cfg.KeyID, func(t *testing.T) {
+			useKeySet(t, cfg)
+			raw, err := signToken(jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+			if err != nil {
+				t.Fatalf("signToken: %v", err)
+			}
+			if err := verify(raw); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerificationKey(t *testing.T) {
+	k := newTestKeys(t)
+	set := useKeySet(t, config.JWTConfig{
+		SigningAlg:     "RS256",
+		PrivateKeyFile: k.rsaFile,
+		VerifyKeys:     []string{"old=" + k.oldRSAPub, "ed=" + k.edPub},
+	})
+	rsaKid := set.signing.ID
+	publicPEM, err := os.ReadFile(k.oldRSAPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"current key", signWith(t, jwt.SigningMethodRS256, k.rsa, rsaKid), ""},
+		{"current key without kid", signWith(t, jwt.SigningMethodRS256, k.rsa, ""), ""},
+		{"rotated RSA key", signWith(t, jwt.SigningMethodRS256, k.oldRSA, "old"), ""},
+		{"rotated Ed25519 key", signWith(t, jwt.SigningMethodEdDSA, k.ed, "ed"), ""},
+		{"unknown kid", signWith(t, jwt.SigningMethodRS256, k.rsa, "missing"), "unknown signing key"},
+		{"old key under the current kid", signWith(t, jwt.SigningMethodRS256, k.oldRSA, rsaKid), "verification error"},
+		// 以 RSA 公钥作为 HMAC 密钥伪造的 Token 不能通过验证
+		{"HS256 signed with the RSA public key", signWith(t, jwt.SigningMethodHS256, publicPEM, "old"), "unexpected signing method"},
+		{"HS256 without kid", signWith(t, jwt.SigningMethodHS256, publicPEM, ""), "unexpected signing method"},
+		{"EdDSA under an RSA kid", signWith(t, jwt.SigningMethodEdDSA, k.ed, "old"), "unexpected signing method"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verify(tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verify error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerificationKeyRejectsAsymmetricTokenForHS256(t *testing.T) {
+	k := newTestKeys(t)
+	useKeySet(t, config.JWTConfig{SigningAlg: "HS256", Secret: "secret"})
+	if err := verify(signWith(t, jwt.SigningMethodRS256, k.rsa, "")); err == nil || !strings.Contains(err.Error(), "unexpected signing method") {
+		t.Fatalf("verify error = %v, want unexpected signing method", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	k := newTestKeys(t)
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	set := useKeySet(t, config.JWTConfig{
+		SigningAlg:     "RS256",
+		PrivateKeyFile: k.rsaFile,
+		VerifyKeys:     []string{"ed=" + k.edPub},
+	})
+	keys, err := JWKS()
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+	want := map[string]JWK{
+		set.signing.ID: {Kty: "RSA", Kid: set.signing.ID, Alg: "RS256", Use: "sig",
+			N: b64(k.rsa.N.Bytes()), E: b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		"ed": {Kty: "OKP", Kid: "ed", Alg: "EdDSA", Use: "sig", Crv: "Ed25519", X: b64(k.ed.Public().(ed25519.PublicKey))},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("JWKS = %+v, want %d keys", keys, len(want))
+	}
+	for _, key := range keys {
+		if key != want[key.Kid] {
+			t.Errorf("JWK %s = %+v, want %+v", key.Kid, key, want[key.Kid])
+		}
+	}
+
+	// HMAC 共享密钥不能公开，只有轮换中的公钥出现在 JWKS 中
+	useKeySet(t, config.JWTConfig{SigningAlg: "HS256", Secret: "secret", KeyID: "hs", VerifyKeys: []string{"old=" + k.oldRSAPub}})
+	keys, err = JWKS()
+	if err != nil {
+		t.Fatalf("JWKS: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Kid != "old" || keys[0].Kty != "RSA" {
+		t.Fatalf("JWKS with HS256 = %+v, want only the old RSA key", keys)
+	}
+}
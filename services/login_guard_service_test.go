@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_core/config"
+	"go_core/lockout"
+	"go_core/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupTestLockout 使用数据库存储，账号失败 3 次锁定，不按 IP 锁定
+func setupTestLockout(t *testing.T) {
+	t.Helper()
+	settings := lockoutSettings
+	t.Cleanup(func() {
+		lockoutSettings = settings
+		SetLockoutStore(lockout.NewMemory(24 * time.Hour))
+	})
+	lockoutSettings = config.LockoutConfig{
+		AccountFailures: 3,
+		BaseDuration:    time.Minute,
+		MaxDuration:     time.Hour,
+		FailureWindow:   24 * time.Hour,
+	}
+	SetLockoutStore(lockout.NewDatabase(config.DB, lockoutSettings.FailureWindow))
+}
+
+func TestLongEmailIsLocked(t *testing.T) {
+	setupTestDB(t)
+	setupTestLockout(t)
+	ctx := context.Background()
+
+	email := strings.Repeat("a", 240) + "@example.com"
+	// login_attempts.subject 的长度为 191
+	if key := accountLockoutKey(email); len(key) > 191 {
+		t.Fatalf("lockout key is %d characters long", len(key))
+	}
+	if accountLockoutKey(" "+strings.ToUpper(email)) != accountLockoutKey(email) {
+		t.Fatal("lockout key depends on case or surrounding spaces")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := CheckLoginThrottle(ctx, email, "192.0.2.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+		if err := RecordLoginFailure(ctx, email, "192.0.2.1", nil); err != nil {
+			t.Fatalf("RecordLoginFailure: %v", err)
+		}
+	}
+	retryAfter, err := CheckLoginThrottle(ctx, email, "192.0.2.1")
+	if !errors.Is(err, ErrLoginLocked) || retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("CheckLoginThrottle = %v, %v; want ErrLoginLocked within a minute", retryAfter, err)
+	}
+
+	// 审计事件中保留邮箱
+	backgroundTasks.Wait()
+	var event models.AuditEvent
+	if err := config.DB.Where("event = ?", models.AuditLoginLocked).First(&event).Error; err != nil {
+		t.Fatalf("no login_locked event: %v", err)
+	}
+	if event.Subject != accountLockoutKey(email) || !strings.Contains(event.Details, email) {
+		t.Fatalf("audit event subject %q, details %q", event.Subject, event.Details)
+	}
+
+	if err := RecordLoginSuccess(ctx, email); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckLoginThrottle(ctx, email, "192.0.2.1"); err != nil {
+		t.Fatalf("after reset: %v", err)
+	}
+}
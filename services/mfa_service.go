@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go_core/config"
+	"go_core/models"
+	"go_core/totp"
+	"shared/apperr"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMFAAlreadyEnabled   = apperr.Conflict("mfa_already_enabled", "Two-factor authentication is already enabled")
+	ErrMFANotEnabled       = apperr.BadRequest("mfa_not_enabled", "Two-factor authentication is not enabled")
+	ErrMFASetupNotStarted  = apperr.BadRequest("mfa_setup_not_started", "Two-factor authentication setup has not been started")
+	ErrInvalidMFACode      = apperr.BadRequest("invalid_mfa_code", "Invalid verification code")
+	ErrInvalidMFAChallenge = apperr.Unauthorized("invalid_mfa_challenge", "invalid or expired MFA challenge, please log in again")
+)
+
+const (
+	mfaSkew           = 1  // 允许前后各一个时间步（30 秒）的时钟偏差
+	recoveryCodeCount = 10 // 每次生成的恢复码数量
+)
+
+// 两步验证的设置，由 InitMFA 根据配置设置
+var (
+	mfaIssuer       = "go_core"
+	mfaChallengeTTL = 5 * time.Minute
+	mfaCipher       cipher.AEAD
+	recoveryCodeKey []byte
+)
+
+// InitMFA 根据配置初始化两步验证，TOTP 密钥使用 AES-256-GCM 加密保存
+// 加密密钥和恢复码的 HMAC 密钥都由 AUTH_MFA_SECRET_KEY 派生
+func InitMFA(cfg config.AuthConfig) error {
+	key := sha256.Sum256([]byte(cfg.MFASecretKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	mfaCipher = aead
+
+	recoveryCodeKey = config.DeriveKey(cfg.MFASecretKey, "mfa recovery code")
+
+	mfaIssuer = cfg.MFAIssuer
+	mfaChallengeTTL = cfg.MFAChallengeTTL
+	return nil
+}
+
+// MFASetup 开始绑定时返回的密钥，前端将 URI 渲染为二维码，无法扫码时可手动输入密钥
+type MFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAStatus 用户的两步验证状态
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// MFAChallenge 密码验证通过但需要两步验证时返回，客户端带上 MFAToken 和验证码调用 /api/login/mfa
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// GetMFAStatus 查询用户是否开启了两步验证以及剩余的恢复码数量
+func GetMFAStatus(ctx context.Context, userID uint) (*MFAStatus, error) {
+	db := config.DB.WithContext(ctx)
+	secret, err := findConfirmedMFASecret(db, userID)
+	if errors.Is(err, ErrMFANotEnabled) {
+		return &MFAStatus{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{Enabled: true, EnabledAt: secret.ConfirmedAt}
+	err = db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&status.RecoveryCodesRemaining).Error
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// MFAEnabled 用户是否已完成两步验证的绑定
+func MFAEnabled(ctx context.Context, userID uint) (bool, error) {
+	var count int64
+	err := config.DB.WithContext(ctx).Model(&models.MFASecret{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// StartMFASetup 为用户生成新的 TOTP 密钥，需要再调用 ConfirmMFASetup 提交验证码才会生效
+// 重复调用时之前未确认的密钥被替换；已开启两步验证时需要先关闭
+func StartMFASetup(ctx context.Context, userID uint, account string) (*MFASetup, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptMFASecret(userID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.MFASecret
+		err := tx.Where("user_id = ?", userID).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.MFASecret{UserID: userID, Secret: encrypted}).Error
+		}
+		if err != nil {
+			return err
+		}
+		if existing.ConfirmedAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+		return tx.Model(&existing).Updates(map[string]interface{}{"secret": encrypted, "last_used_step": 0}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MFASetup{Secret: secret, URI: totp.URI(mfaIssuer, account, secret)}, nil
+}
+
+// ConfirmMFASetup 用验证器应用生成的验证码确认绑定，开启两步验证并返回恢复码
+// 恢复码只在此时返回一次，数据库中只保存 HMAC
+func ConfirmMFASetup(ctx context.Context, userID uint, code, ip string) ([]string, error) {
+	var codes []string
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var secret models.MFASecret
+		err := tx.Where("user_id = ?", userID).First(&secret).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMFASetupNotStarted
+		}
+		if err != nil {
+			return err
+		}
+		if secret.ConfirmedAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+
+		plain, err := decryptMFASecret(userID, secret.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(plain, code, time.Now(), mfaSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		// 以 confirmed_at 为空作为条件更新，并发确认时只有一个请求成功
+		result := tx.Model(&models.MFASecret{}).
+			Where("id = ? AND confirmed_at IS NULL", secret.ID).
+			Updates(map[string]interface{}{"confirmed_at": time.Now(), "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAAlreadyEnabled
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recordAudit(ctx, models.AuditEvent{Event: models.AuditMFAEnabled, UserID: &userID, IP: ip}, nil)
+	return codes, nil
+}
+
+// VerifyMFACode 校验两步验证码，code 可以是 6 位 TOTP 验证码或恢复码
+// 同一个 TOTP 验证码只能使用一次，恢复码使用后立即失效
+func VerifyMFACode(ctx context.Context, userID uint, code, ip string) error {
+	db := config.DB.WithContext(ctx)
+	normalized := normalizeMFACode(code)
+	if len(normalized) != totp.Digits || strings.Trim(normalized, "0123456789") != "" {
+		return useRecoveryCode(ctx, userID, normalized, ip)
+	}
+
+	secret, err := findConfirmedMFASecret(db, userID)
+	if err != nil {
+		return err
+	}
+	plain, err := decryptMFASecret(userID, secret.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(plain, normalized, time.Now(), mfaSkew)
+	if !ok || step <= secret.LastUsedStep {
+		return ErrInvalidMFACode
+	}
+
+	// 以 last_used_step 作为条件更新，同一个验证码并发提交时只有一个请求成功
+	result := db.Model(&models.MFASecret{}).
+		Where("id = ? AND last_used_step < ?", secret.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// DisableMFA 用户校验验证码或恢复码后关闭两步验证，密钥和恢复码一并删除
+func DisableMFA(ctx context.Context, userID uint, code, ip string) error {
+	if err := VerifyMFACode(ctx, userID, code, ip); err != nil {
+		return err
+	}
+	if err := deleteMFA(ctx, userID); err != nil {
+		return err
+	}
+	recordAudit(ctx, models.AuditEvent{Event: models.AuditMFADisabled, UserID: &userID, IP: ip}, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，之前的恢复码全部失效
+func RegenerateRecoveryCodes(ctx context.Context, userID uint, code, ip string) ([]string, error) {
+	if err := VerifyMFACode(ctx, userID, code, ip); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordAudit(ctx, models.AuditEvent{Event: models.AuditMFARecoveryCodesRegenerated, UserID: &userID, IP: ip}, nil)
+	return codes, nil
+}
+
+// ResetMFA 管理员为丢失验证器和恢复码的用户关闭两步验证，用户下次登录只需要密码
+func ResetMFA(ctx context.Context, actorID, userID uint, ip string) error {
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if err := deleteMFA(ctx, user.ID); err != nil {
+		return err
+	}
+	recordAudit(ctx, models.AuditEvent{Event: models.AuditMFAReset, UserID: &user.ID, ActorID: &actorID, IP: ip}, nil)
+	return nil
+}
+
+// IssueMFAChallenge 密码验证通过后签发两步验证的挑战令牌，在 AUTH_MFA_CHALLENGE_TTL 内有效
+// 令牌与邮箱验证令牌一样只保存哈希，登录成功后即失效，同一用户再次签发时之前的挑战令牌随即失效
+func IssueMFAChallenge(ctx context.Context, userID uint) (*MFAChallenge, error) {
+	token, err := issueAccountToken(ctx, userID, models.TokenPurposeMFAChallenge, mfaChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{MFARequired: true, MFAToken: token, ExpiresIn: int64(mfaChallengeTTL.Seconds())}, nil
+}
+
+// VerifyMFAChallenge 校验挑战令牌并返回对应的用户，令牌不会被消耗，验证码错误时可以重试
+func VerifyMFAChallenge(ctx context.Context, token string) (*models.User, error) {
+	db := config.DB.WithContext(ctx)
+	record, err := findAccountToken(db, token, models.TokenPurposeMFAChallenge)
+	if errors.Is(err, ErrInvalidAccountToken) {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	err = db.First(&user, record.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ConsumeMFAChallenge 验证码通过后将挑战令牌标记为已使用，同一个令牌只能完成一次登录
+func ConsumeMFAChallenge(ctx context.Context, token string) error {
+	_, err := consumeAccountToken(config.DB.WithContext(ctx), token, models.TokenPurposeMFAChallenge)
+	if errors.Is(err, ErrInvalidAccountToken) {
+		return ErrInvalidMFAChallenge
+	}
+	return err
+}
+
+// findConfirmedMFASecret 查询用户已确认的 TOTP 密钥，未开启时返回 ErrMFANotEnabled
+func findConfirmedMFASecret(db *gorm.DB, userID uint) (*models.MFASecret, error) {
+	var secret models.MFASecret
+	err := db.Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&secret).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// useRecoveryCode 使用一个恢复码，以 used_at 为空作为条件更新，同一个恢复码只能成功使用一次
+func useRecoveryCode(ctx context.Context, userID uint, normalized, ip string) error {
+	if normalized == "" {
+		return ErrInvalidMFACode
+	}
+	db := config.DB.WithContext(ctx)
+	if _, err := findConfirmedMFASecret(db, userID); err != nil {
+		return err
+	}
+
+	result := db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(userID, normalized)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	recordAudit(ctx, models.AuditEvent{Event: models.AuditMFARecoveryCodeUsed, UserID: &userID, IP: ip}, nil)
+	return nil
+}
+
+// replaceRecoveryCodes 删除用户的全部恢复码并生成新的一组，返回明文
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = models.MFARecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(userID, normalizeMFACode(code))}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// deleteMFA 删除用户的 TOTP 密钥和恢复码
+func deleteMFA(ctx context.Context, userID uint) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFASecret{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+	})
+}
+
+// recoveryCodeEncoding 恢复码使用小写的 Base32，不含容易混淆的 0、1、8、9
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCode 生成 50 位随机数的恢复码，格式为 xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := recoveryCodeEncoding.EncodeToString(b)[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode 计算恢复码的 HMAC-SHA256，带上用户 ID，相同的恢复码在不同用户下的哈希不同
+// 恢复码只有 50 位随机数，不加密钥的哈希泄露后可以离线穷举，密钥不随数据库一起保存
+func hashRecoveryCode(userID uint, normalized string) string {
+	mac := hmac.New(sha256.New, recoveryCodeKey)
+	mac.Write([]byte(strconv.FormatUint(uint64(userID), 10) + ":" + normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// normalizeMFACode 去掉用户输入中的空格和连字符，恢复码不区分大小写
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// encryptMFASecret 加密 TOTP 密钥，用户 ID 作为附加数据，密文不能挪用到其他用户
+func encryptMFASecret(userID uint, secret string) (string, error) {
+	if mfaCipher == nil {
+		return "", errors.New("MFA is not initialized")
+	}
+	nonce := make([]byte, mfaCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := mfaCipher.Seal(nonce, nonce, []byte(secret), []byte(strconv.FormatUint(uint64(userID), 10)))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptMFASecret 解密 TOTP 密钥，AUTH_MFA_SECRET_KEY 变更后无法解密
+func decryptMFASecret(userID uint, encrypted string) (string, error) {
+	if mfaCipher == nil {
+		return "", errors.New("MFA is not initialized")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < mfaCipher.NonceSize() {
+		return "", errors.New("invalid encrypted MFA secret")
+	}
+	nonce, ciphertext := sealed[:mfaCipher.NonceSize()], sealed[mfaCipher.NonceSize():]
+	plain, err := mfaCipher.Open(nil, nonce, ciphertext, []byte(strconv.FormatUint(uint64(userID), 10)))
+	if err != nil {
+		return "", errors.New("failed to decrypt MFA secret, check AUTH_MFA_SECRET_KEY")
+	}
+	return string(plain), nil
+}
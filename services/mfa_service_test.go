@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_core/config"
+	"go_core/models"
+	"go_core/totp"
+	"testing"
+	"time"
+)
+
+// setupTestMFA 使用测试密钥初始化两步验证
+func setupTestMFA(t *testing.T) {
+	t.Helper()
+	err := InitMFA(config.AuthConfig{MFAIssuer: "go_core", MFASecretKey: "test-mfa-secret-key", MFAChallengeTTL: 5 * time.Minute})
+	if err != nil {
+		t.Fatalf("InitMFA: %v", err)
+	}
+}
+
+// enableTestMFA 为用户绑定两步验证，返回 TOTP 密钥和恢复码
+func enableTestMFA(t *testing.T, user *models.User) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	setup, err := StartMFASetup(ctx, user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("StartMFASetup: %v", err)
+	}
+	code, err := totp.Code(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := ConfirmMFASetup(ctx, user.ID, code, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("ConfirmMFASetup: %v", err)
+	}
+	return setup.Secret, codes
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	setupTestDB(t)
+	setupTestMFA(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+	other := createTestUser(t, "other@example.com")
+
+	_, codes := enableTestMFA(t, user)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// 数据库中保存的是带密钥和用户 ID 的 HMAC，不是恢复码的普通哈希
+	normalized := normalizeMFACode(codes[0])
+	var stored models.MFARecoveryCode
+	if err := config.DB.Where("user_id = ?", user.ID).Order("id").First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.CodeHash == hashToken(normalized) {
+		t.Fatal("recovery code is stored as an unkeyed SHA-256 hash")
+	}
+	if hashRecoveryCode(user.ID, normalized) == hashRecoveryCode(other.ID, normalized) {
+		t.Fatal("recovery code hash does not depend on the user")
+	}
+
+	// 恢复码不区分大小写和连字符，只能使用一次
+	if err := VerifyMFACode(ctx, user.ID, " "+normalized[:5]+" "+normalized[5:]+" ", "127.0.0.1"); err != nil {
+		t.Fatalf("VerifyMFACode with a recovery code: %v", err)
+	}
+	if err := VerifyMFACode(ctx, user.ID, codes[0], "127.0.0.1"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("reusing a recovery code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	// 其他用户不能使用该用户的恢复码
+	enableTestMFA(t, other)
+	if err := VerifyMFACode(ctx, other.ID, codes[1], "127.0.0.1"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("another user's recovery code error = %v, want ErrInvalidMFACode", err)
+	}
+
+	status, err := GetMFAStatus(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RecoveryCodesRemaining != int64(recoveryCodeCount-1) {
+		t.Fatalf("%d recovery codes remaining, want %d", status.RecoveryCodesRemaining, recoveryCodeCount-1)
+	}
+}
+
+func TestMFAChallengeIsSingleUse(t *testing.T) {
+	setupTestDB(t)
+	setupTestMFA(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	challenge, err := IssueMFAChallenge(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("IssueMFAChallenge: %v", err)
+	}
+
+	// 校验不消耗令牌，验证码错误后可以重试
+	for i := 0; i < 2; i++ {
+		got, err := VerifyMFAChallenge(ctx, challenge.MFAToken)
+		if err != nil {
+			t.Fatalf("VerifyMFAChallenge #%d: %v", i, err)
+		}
+		if got.ID != user.ID {
+			t.Fatalf("challenge belongs to user %d, want %d", got.ID, user.ID)
+		}
+	}
+
+	if err := ConsumeMFAChallenge(ctx, challenge.MFAToken); err != nil {
+		t.Fatalf("ConsumeMFAChallenge: %v", err)
+	}
+	if _, err := VerifyMFAChallenge(ctx, challenge.MFAToken); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Fatalf("VerifyMFAChallenge after use error = %v, want ErrInvalidMFAChallenge", err)
+	}
+	if err := ConsumeMFAChallenge(ctx, challenge.MFAToken); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Fatalf("second ConsumeMFAChallenge error = %v, want ErrInvalidMFAChallenge", err)
+	}
+
+	// 邮箱验证等其他用途的令牌不能作为挑战令牌
+	token, err := issueAccountToken(ctx, user.ID, models.TokenPurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyMFAChallenge(ctx, token); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Fatalf("reset token as a challenge error = %v, want ErrInvalidMFAChallenge", err)
+	}
+}
+
+func TestMFAChallengeIsInvalidatedByANewerChallenge(t *testing.T) {
+	setupTestDB(t)
+	setupTestMFA(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	first, err := IssueMFAChallenge(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := IssueMFAChallenge(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyMFAChallenge(ctx, first.MFAToken); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Fatalf("older challenge error = %v, want ErrInvalidMFAChallenge", err)
+	}
+	if _, err := VerifyMFAChallenge(ctx, second.MFAToken); err != nil {
+		t.Fatalf("newer challenge: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"go_core/config"
+	"go_core/models"
+	"shared/apperr"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordTooLong 密码超过 bcrypt 的 72 字节限制，请求体校验已拦截，这里作为兜底
+var ErrPasswordTooLong = apperr.Validation("Invalid input", apperr.FieldError{Field: "password", Message: "password must be at most 72 bytes"})
+
+// bcryptCost bcrypt 计算成本，由 SetBcryptCost 根据配置设置
+var bcryptCost = 12
+
+// SetBcryptCost 设置新密码哈希使用的计算成本，非法值被忽略
+func SetBcryptCost(cost int) {
+	if cost >= bcrypt.MinCost && cost <= bcrypt.MaxCost {
+		bcryptCost = cost
+	}
+}
+
+// isBcryptHash 判断存储的密码是否为 bcrypt 哈希（$2a$ / $2b$ / $2y$ 前缀）
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// HashPassword 使用 bcrypt 对明文密码进行哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 验证密码，同时兼容历史遗留的明文密码
+func CheckPassword(storedPassword, providedPassword string) bool {
+	if !isBcryptHash(storedPassword) {
+		// 旧数据以明文存储，登录成功后会通过 RehashPasswordIfNeeded 升级
+		// 使用常量时间比较，避免通过响应时间逐字节猜测明文密码
+		return storedPassword != "" && subtle.ConstantTimeCompare([]byte(storedPassword), []byte(providedPassword)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(providedPassword)) == nil
+}
+
+// NeedsRehash 判断存储的密码是否需要重新哈希（明文或成本低于当前配置）
+func NeedsRehash(storedPassword string) bool {
+	if !isBcryptHash(storedPassword) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(storedPassword))
+	if err != nil {
+		return true
+	}
+	return cost < bcryptCost
+}
+
+// RehashPasswordIfNeeded 在登录成功后就地升级明文或弱成本的密码哈希
+func RehashPasswordIfNeeded(ctx context.Context, user *models.User, providedPassword string) error {
+	if !NeedsRehash(user.Password) {
+		return nil
+	}
+
+	hash, err := HashPassword(providedPassword)
+	if err != nil {
+		return err
+	}
+
+	// 只更新 password 字段，并以旧值作为条件，避免覆盖并发修改
+	result := config.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		Update("password", hash)
+	if result.Error != nil {
+		return result.Error
+	}
+	user.Password = hash
+	return nil
+}
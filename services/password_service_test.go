@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"shared/apperr"
+	"strings"
+	"testing"
+)
+
+func TestHashPasswordTooLong(t *testing.T) {
+	SetBcryptCost(4)
+
+	// 25 个中文字符只有 25 个字符，但有 75 字节，超过 bcrypt 的限制
+	_, err := HashPassword(strings.Repeat("密", 25))
+	if !errors.Is(err, ErrPasswordTooLong) {
+		t.Fatalf("HashPassword error = %v, want ErrPasswordTooLong", err)
+	}
+	if status := apperr.From(err).Status; status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+
+	hash, err := HashPassword(strings.Repeat("密", 24))
+	if err != nil {
+		t.Fatalf("HashPassword(72 bytes): %v", err)
+	}
+	if !CheckPassword(hash, strings.Repeat("密", 24)) {
+		t.Fatal("CheckPassword failed for a 72 byte password")
+	}
+}
+
+func TestCheckPasswordLegacyPlaintext(t *testing.T) {
+	SetBcryptCost(4)
+	if !CheckPassword("secret-pass", "secret-pass") || CheckPassword("secret-pass", "other") {
+		t.Fatal("plaintext comparison is wrong")
+	}
+	if CheckPassword("", "") {
+		t.Fatal("empty stored password must not match")
+	}
+	if !NeedsRehash("secret-pass") {
+		t.Fatal("plaintext password should need a rehash")
+	}
+	hash, err := HashPassword("secret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(hash) {
+		t.Fatal("hash with the current cost should not need a rehash")
+	}
+	SetBcryptCost(5)
+	defer SetBcryptCost(4)
+	if !NeedsRehash(hash) {
+		t.Fatal("hash with a lower cost should need a rehash")
+	}
+}
@@ -1,50 +1,325 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"go_core/config"
+	"go_core/imageproc"
 	"go_core/models"
 	"go_core/utils"
+	"shared/apperr"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidProduct         = apperr.BadRequest("invalid_product", "Invalid product data")
+	ErrProductNotFound        = apperr.NotFound("product_not_found", "product not found")
+	ErrProductVersionConflict = apperr.Conflict("version_conflict", "product has been modified, please reload and retry")
+	ErrInvalidProductImage    = apperr.BadRequest("invalid_product_image", "image_id must reference an uploaded image")
+)
+
+// productFullTextIndex 产品名称的 FULLTEXT 索引，仅 MySQL 下创建
+const productFullTextIndex = "idx_products_name_fulltext"
+
+var (
+	productFullTextOnce    sync.Once
+	productFullTextEnabled bool
+)
+
+// useProductFullText 判断是否可以使用 FULLTEXT 搜索，结果在首次调用时缓存
+func useProductFullText() bool {
+	productFullTextOnce.Do(func() {
+		productFullTextEnabled = config.DB.Dialector.Name() == "mysql" &&
+			config.DB.Migrator().HasIndex(&models.Product{}, productFullTextIndex)
+	})
+	return productFullTextEnabled
+}
+
+// productQueryOptions 产品列表允许的搜索、过滤、排序和字段选择
+func productQueryOptions() utils.QueryOptions {
+	fields := map[string]utils.Field{
+		"id":         {Column: "id", JSONKey: "ID"},
+		"name":       {Column: "name", JSONKey: "name"},
+		"price":      {Column: "price", JSONKey: "price"},
+		"version":    {Column: "version", JSONKey: "version"},
+		"created_at": {Column: "created_at", JSONKey: "CreatedAt", Type: utils.RangeTime},
+		"updated_at": {Column: "updated_at", JSONKey: "UpdatedAt", Type: utils.RangeTime},
+	}
+	// 图片只能选择，不能排序
+	selectable := map[string]utils.Field{"image": {Column: "image_id", JSONKey: "image"}}
+	for name, field := range fields {
+		selectable[name] = field
+	}
+
+	return utils.QueryOptions{
+		SearchColumns: []string{"name"},
+		FullText:      useProductFullText(),
+		Ranges: map[string]utils.RangeField{
+			"price":      {Column: "price", Type: utils.RangeNumber},
+			"created_at": {Column: "created_at", Type: utils.RangeTime},
+		},
+		Sorts:       fields,
+		Fields:      selectable,
+		DefaultSort: []utils.SortField{{Name: "id", Column: "id"}},
+		KeyField:    "id",
+	}
+}
+
 // GetProductsWithPagination 获取产品列表，并返回分页信息
-func GetProductsWithPagination(c *gin.Context) ([]models.Product, utils.Pagination, error) {
+// 支持搜索、价格和创建时间范围过滤、多字段排序以及字段选择，参数格式见 utils.ParseQuerySpec
+// 携带 cursor 或 mode=cursor 时使用游标分页，响应中返回 NextCursor / PrevCursor
+// 产品图片连同所有衍生版本的地址一起返回
+func GetProductsWithPagination(c *gin.Context) (interface{}, utils.Pagination, error) {
 	// 获取分页参数
 	pagination := utils.GetPagination(c)
 
-	// 获取偏移量和限制
-	offset, limit := pagination.Paginate()
+	// 解析查询条件
+	spec, err := utils.ParseQuerySpec(c, productQueryOptions())
+	if err != nil {
+		return nil, pagination, err
+	}
+
+	db := config.DB.WithContext(c.Request.Context())
 
 	// 查询产品列表
 	var products []models.Product
-	if err := config.DB.Offset(offset).Limit(limit).Find(&products).Error; err != nil {
-		return nil, pagination, err
+	query := spec.ApplySelect(spec.ApplyFilters(db.Model(&models.Product{}))).Preload("Image.Variants")
+	if pagination.IsCursor() {
+		// 游标分页：按排序键定位，不使用 OFFSET
+		if query, err = spec.ApplyKeyset(query, &pagination); err != nil {
+			return nil, pagination, err
+		}
+		if err := query.Find(&products).Error; err != nil {
+			return nil, pagination, err
+		}
+		if err := spec.FinishKeyset(&products, &pagination); err != nil {
+			return nil, pagination, err
+		}
+	} else {
+		// 获取偏移量和限制
+		offset, limit := pagination.Paginate()
+
+		if err := spec.ApplySort(query).Offset(offset).Limit(limit).Find(&products).Error; err != nil {
+			return nil, pagination, err
+		}
 	}
 
 	// 查询总记录数
 	var total int64
-	if err := config.DB.Model(&models.Product{}).Count(&total).Error; err != nil {
+	if err := spec.ApplyFilters(db.Model(&models.Product{})).Count(&total).Error; err != nil {
 		return nil, pagination, err
 	}
 
 	// 设置总记录数
 	pagination.Total = total
 
-	return products, pagination, nil
+	// 裁剪为选中的字段
+	list, err := spec.Project(products)
+	if err != nil {
+		return nil, pagination, err
+	}
+
+	return list, pagination, nil
+}
+
+// validateProduct 验证产品信息是否有效
+func validateProduct(name string, price float64) error {
+	if name == "" || price <= 0 {
+		return ErrInvalidProduct
+	}
+	return nil
 }
 
-func CreateProduct(product *models.Product) error {
+// validateProductImage 验证产品图片引用的是已上传的图片文件
+// 被产品引用的图片所有登录用户都可以访问，因此只能引用自己上传的图片，拥有 files:read_all 权限的用户除外；
+// productID 不为 0 时允许保留产品当前的图片，即使它由其他用户上传
+func validateProductImage(ctx context.Context, imageID *uint, claims *Claims, productID uint) error {
+	if imageID == nil {
+		return nil
+	}
+	db := config.DB.WithContext(ctx)
+	var file models.File
+	if err := db.First(&file, *imageID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidProductImage
+		}
+		return err
+	}
+	if !imageproc.IsImage(file.MimeType) {
+		return ErrInvalidProductImage
+	}
+	if file.UserID == claims.UserID {
+		return nil
+	}
+
+	allowed, err := HasPermission(ctx, claims.Roles, "files:read_all")
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return nil
+	}
+	if productID != 0 {
+		var count int64
+		if err := db.Model(&models.Product{}).Where("id = ? AND image_id = ?", productID, file.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+	// 与图片不存在返回相同的错误，不泄露其他用户的文件 ID
+	return ErrInvalidProductImage
+}
+
+// CreateProduct 创建产品，claims 为当前用户，用于校验产品图片的归属
+func CreateProduct(ctx context.Context, product *models.Product, claims *Claims) error {
 	// 验证产品信息是否有效
-	if product.Name == "" || product.Price <= 0 {
-		return errors.New("Invalid product data")
+	if err := validateProduct(product.Name, product.Price); err != nil {
+		return err
 	}
+	if err := validateProductImage(ctx, product.ImageID, claims, 0); err != nil {
+		return err
+	}
+
+	// 新产品的主键和版本号由数据库生成，忽略客户端传入的值；图片只能通过 image_id 引用
+	product.ID = 0
+	product.Version = 1
+	product.Image = nil
 
 	// 创建产品
-	if err := config.DB.Create(&product).Error; err != nil {
+	if err := config.DB.WithContext(ctx).Create(product).Error; err != nil {
+		return err
+	}
+
+	// 重新读取以返回图片信息
+	created, err := GetProductByID(ctx, product.ID)
+	if err != nil {
 		return err
 	}
+	*product = *created
+	return nil
+}
+
+// GetProductByID 根据 ID 获取产品，包含图片及其衍生版本
+func GetProductByID(ctx context.Context, id uint) (*models.Product, error) {
+	var product models.Product
+	if err := config.DB.WithContext(ctx).Preload("Image.Variants").First(&product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	return &product, nil
+}
+
+// updateProductWithVersion 仅当版本号匹配时更新产品，并将版本号加 1
+func updateProductWithVersion(ctx context.Context, id, version uint, updates map[string]interface{}) (*models.Product, error) {
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := config.DB.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		// 区分产品不存在和版本冲突
+		if _, err := GetProductByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrProductVersionConflict
+	}
+
+	return GetProductByID(ctx, id)
+}
+
+// UpdateProduct 整体更新产品，version 为客户端读取时的版本号
+// ImageID 为空时移除产品图片
+func UpdateProduct(ctx context.Context, id uint, input models.Product, version uint, claims *Claims) (*models.Product, error) {
+	if err := validateProduct(input.Name, input.Price); err != nil {
+		return nil, err
+	}
+	if err := validateProductImage(ctx, input.ImageID, claims, id); err != nil {
+		return nil, err
+	}
+
+	return updateProductWithVersion(ctx, id, version, map[string]interface{}{
+		"name":     input.Name,
+		"price":    input.Price,
+		"image_id": input.ImageID,
+	})
+}
+
+// PatchProduct 部分更新产品，只修改非空字段，imageID 为 0 时移除产品图片
+func PatchProduct(ctx context.Context, id uint, name *string, price *float64, imageID *uint, version uint, claims *Claims) (*models.Product, error) {
+	updates := map[string]interface{}{}
+	if name != nil {
+		if *name == "" {
+			return nil, ErrInvalidProduct
+		}
+		updates["name"] = *name
+	}
+	if price != nil {
+		if *price <= 0 {
+			return nil, ErrInvalidProduct
+		}
+		updates["price"] = *price
+	}
+	if imageID != nil {
+		if *imageID == 0 {
+			updates["image_id"] = nil
+		} else {
+			if err := validateProductImage(ctx, imageID, claims, id); err != nil {
+				return nil, err
+			}
+			updates["image_id"] = *imageID
+		}
+	}
 
+	return updateProductWithVersion(ctx, id, version, updates)
+}
+
+// DeleteProduct 软删除产品
+func DeleteProduct(ctx context.Context, id uint) error {
+	result := config.DB.WithContext(ctx).Delete(&models.Product{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProductNotFound
+	}
 	return nil
 }
+
+// RestoreProduct 恢复被软删除的产品
+func RestoreProduct(ctx context.Context, id uint) (*models.Product, error) {
+	var product models.Product
+	if err := config.DB.WithContext(ctx).Unscoped().First(&product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	// 未被删除的产品直接返回
+	if !product.DeletedAt.Valid {
+		return &product, nil
+	}
+
+	err := config.DB.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProductByID(ctx, id)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"go_core/config"
+	"shared/ratelimit"
+)
+
+// rateLimitStore 限流令牌桶的存储，由 InitRateLimit 根据配置设置
+var rateLimitStore ratelimit.Store = ratelimit.NewMemory()
+
+// InitRateLimit 根据配置初始化限流存储，redis 驱动时创建客户端，退出时由 CloseRateLimit 关闭
+func InitRateLimit(cfg config.RateLimitConfig) error {
+	store, err := ratelimit.New(ratelimit.Config{
+		Driver:   cfg.Store,
+		RedisURL: cfg.RedisURL,
+	})
+	if err != nil {
+		return err
+	}
+	rateLimitStore = store
+	return nil
+}
+
+// SetRateLimitStore 替换限流存储，测试时可传入内存存储或指向进程内 Redis 替身的存储
+func SetRateLimitStore(store ratelimit.Store) {
+	rateLimitStore = store
+}
+
+// CloseRateLimit 关闭限流存储的连接
+func CloseRateLimit(ctx context.Context) error {
+	return rateLimitStore.Close()
+}
+
+// RateLimitStore 返回当前的限流存储，路由在 InitRateLimit 之后注册
+func RateLimitStore() ratelimit.Store {
+	return rateLimitStore
+}
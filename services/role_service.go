@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"go_core/config"
+	"go_core/models"
+	"shared/apperr"
+)
+
+var (
+	ErrRoleNotFound = apperr.NotFound("role_not_found", "role not found")
+	ErrUserNotFound = apperr.NotFound("user_not_found", "user not found")
+)
+
+// GetUserRoleNames 获取用户拥有的角色名称
+func GetUserRoleNames(ctx context.Context, userID uint) ([]string, error) {
+	var names []string
+	err := config.DB.WithContext(ctx).Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.deleted_at IS NULL", userID).
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// HasPermission 判断角色列表中是否有任一角色拥有指定权限
+func HasPermission(ctx context.Context, roles []string, permission string) (bool, error) {
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	var count int64
+	err := config.DB.WithContext(ctx).Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name IN ? AND permissions.name = ?", roles, permission).
+		Where("roles.deleted_at IS NULL AND permissions.deleted_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListRoles 获取所有角色及其权限
+func ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if err := config.DB.WithContext(ctx).Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// findUserAndRole 查找用户和角色，供授予和撤销角色使用
+func findUserAndRole(ctx context.Context, userID uint, roleName string) (*models.User, *models.Role, error) {
+	db := config.DB.WithContext(ctx)
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	var role models.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, nil, ErrRoleNotFound
+	}
+	return &user, &role, nil
+}
+
+// GrantRole 为用户授予角色，下次签发 Token 时生效
+func GrantRole(ctx context.Context, userID uint, roleName string) error {
+	user, role, err := findUserAndRole(ctx, userID, roleName)
+	if err != nil {
+		return err
+	}
+	return config.DB.WithContext(ctx).Model(user).Association("Roles").Append(role)
+}
+
+// RevokeRole 撤销用户的角色，下次签发 Token 时生效
+func RevokeRole(ctx context.Context, userID uint, roleName string) error {
+	user, role, err := findUserAndRole(ctx, userID, roleName)
+	if err != nil {
+		return err
+	}
+	return config.DB.WithContext(ctx).Model(user).Association("Roles").Delete(role)
+}
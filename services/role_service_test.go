@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"go_core/config"
+	"go_core/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSeedGrantsAdminOnlyToVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	const adminEmail = "admin@example.com"
+
+	// 抢先注册、尚未验证邮箱的账号不会被授予管理员
+	user := models.User{Name: "squatter", Email: adminEmail, Password: "x"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := models.Seed(adminEmail); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	roles, err := GetUserRoleNames(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserRoleNames: %v", err)
+	}
+	if slices.Contains(roles, models.RoleAdmin) {
+		t.Fatalf("unverified user got roles %v, want no admin", roles)
+	}
+
+	// 验证邮箱后再次执行 Seed 时授予
+	now := time.Now()
+	if err := config.DB.Model(&user).Update("email_verified_at", &now).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Seed(adminEmail); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	roles, err = GetUserRoleNames(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserRoleNames: %v", err)
+	}
+	if !slices.Contains(roles, models.RoleAdmin) {
+		t.Fatalf("verified user got roles %v, want admin", roles)
+	}
+}
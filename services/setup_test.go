@@ -0,0 +1,56 @@
+package services
+
+import (
+	"go_core/config"
+	_ "go_core/migrations"
+	"go_core/models"
+	"go_core/storage"
+	"path/filepath"
+	"shared/migrate"
+	"testing"
+	"time"
+)
+
+// setupTestDB 为每个测试创建独立的 SQLite 数据库并执行全部迁移和内置角色初始化，
+// 同时使用内存存储，测试结束后关闭连接
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	err := config.InitDB(config.DatabaseConfig{
+		Driver:       "sqlite",
+		Name:         filepath.Join(t.TempDir(), "test.db"),
+		MaxOpenConns: 4,
+		MaxIdleConns: 4,
+	})
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := config.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := migrate.Up(config.DB); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if err := models.Seed(""); err != nil {
+		t.Fatalf("seed roles: %v", err)
+	}
+	SetStorage(storage.NewMemory())
+	SetBcryptCost(4)
+}
+
+// createTestUser 直接写入一个邮箱已验证的用户，密码为 password123
+func createTestUser(t *testing.T, email string) *models.User {
+	t.Helper()
+	hash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	now := time.Now()
+	user := models.User{Name: "test", Email: email, Password: hash, EmailVerifiedAt: &now}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
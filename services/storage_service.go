@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_core/config"
+	"go_core/imageproc"
+	"go_core/models"
+	"go_core/storage"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"shared/apperr"
+	"shared/tracing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultUploadMaxSize 未配置路由限制时允许的最大上传大小（10MB）
+const DefaultUploadMaxSize int64 = 10 << 20
+
+var (
+	ErrFileTooLarge        = apperr.New(http.StatusRequestEntityTooLarge, "file_too_large", "file is too large")
+	ErrUnsupportedFileType = apperr.New(http.StatusUnsupportedMediaType, "unsupported_file_type", "unsupported file type")
+	ErrFileNotFound        = apperr.NotFound("file_not_found", "file not found")
+	ErrFileForbidden       = apperr.Forbidden("file_forbidden", "no permission to access this file")
+)
+
+// allowedUploadTypes 允许上传的 MIME 类型及其扩展名，类型由文件内容嗅探得到
+var allowedUploadTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// UploadOptions 单次上传的限制
+type UploadOptions struct {
+	MaxSize      int64    // 最大字节数
+	ImageMaxSize int64    // 图片的最大字节数，图片需读入内存去除元数据，为 0 时与 MaxSize 相同
+	AllowedTypes []string // 允许的 MIME 类型，为空时使用 allowedUploadTypes 中的全部类型
+}
+
+// presignExpiry 预签名下载地址的有效期
+const presignExpiry = 15 * time.Minute
+
+// fileStorage 上传文件使用的存储后端
+var fileStorage storage.Backend
+
+// InitStorage 根据配置初始化存储后端
+func InitStorage(cfg config.StorageConfig) error {
+	backend, err := storage.New(storage.Config{
+		Driver:   cfg.Driver,
+		LocalDir: cfg.LocalDir,
+		S3: storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Transport: tracing.Transport(http.DefaultTransport),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fileStorage = backend
+	return nil
+}
+
+// SetStorage 替换存储后端，测试时可传入 storage.NewMemory()
+func SetStorage(backend storage.Backend) {
+	fileStorage = backend
+}
+
+// isAllowedType 判断 MIME 类型是否在允许列表中
+func (o UploadOptions) isAllowedType(mimeType string) bool {
+	if _, ok := allowedUploadTypes[mimeType]; !ok {
+		return false
+	}
+	if len(o.AllowedTypes) == 0 {
+		return true
+	}
+	for _, allowed := range o.AllowedTypes {
+		if allowed == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveUpload 校验并保存上传的文件，返回文件记录
+// 文件名由内容的 SHA-256 生成，不使用客户端提供的文件名，避免路径穿越和覆盖
+func SaveUpload(ctx context.Context, header *multipart.FileHeader, userID uint, opts UploadOptions) (*models.File, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultUploadMaxSize
+	}
+	if header.Size > opts.MaxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return saveContent(ctx, src, header.Filename, userID, opts)
+}
+
+// saveContent 嗅探类型、去除图片元数据、按哈希写入存储后端并创建文件记录
+// 普通上传和断点续传完成后都通过这里保存
+func saveContent(ctx context.Context, src io.ReadSeeker, filename string, userID uint, opts UploadOptions) (*models.File, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultUploadMaxSize
+	}
+
+	// 根据文件内容嗅探类型，不信任客户端提供的 Content-Type
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(src, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	mimeType := http.DetectContentType(sniff[:n])
+	if !opts.isAllowedType(mimeType) {
+		return nil, ErrUnsupportedFileType
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// 图片先去除 EXIF 等元数据（可能包含拍摄位置），哈希按处理后的内容计算
+	var body io.Reader = src
+	if imageproc.IsImage(mimeType) {
+		limit := opts.MaxSize
+		if opts.ImageMaxSize > 0 && opts.ImageMaxSize < limit {
+			limit = opts.ImageMaxSize
+		}
+		data, err := io.ReadAll(io.LimitReader(src, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, ErrFileTooLarge
+		}
+		if data, err = imageproc.StripMetadata(data, mimeType); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedFileType, err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	// 先写入本地临时文件并计算哈希，得到最终文件名后再写入存储后端
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(body, opts.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > opts.MaxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	storedName := checksum + allowedUploadTypes[mimeType]
+
+	// 相同内容已存在时不再重复写入
+	exists, err := fileStorage.Exists(ctx, storedName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := fileStorage.Put(ctx, storedName, tmp, size, mimeType); err != nil {
+			return nil, err
+		}
+	}
+
+	file := models.File{
+		UserID:       userID,
+		OriginalName: filepath.Base(filename),
+		StoredName:   storedName,
+		MimeType:     mimeType,
+		Size:         size,
+		Checksum:     checksum,
+	}
+	// 图片的缩略图在后台生成
+	if imageproc.IsImage(mimeType) {
+		file.ProcessingStatus = models.ProcessingPending
+	}
+	if err := config.DB.WithContext(ctx).Create(&file).Error; err != nil {
+		return nil, err
+	}
+	if file.ProcessingStatus == models.ProcessingPending {
+		enqueueImage(file.ID)
+	}
+	return &file, nil
+}
+
+// GetFileForUser 获取文件记录及其衍生版本
+// 上传者、拥有 files:read_all 权限的用户可以访问；被产品引用的图片所有登录用户都可以访问
+func GetFileForUser(ctx context.Context, id uint, claims *Claims) (*models.File, error) {
+	db := config.DB.WithContext(ctx)
+
+	var file models.File
+	if err := db.Preload("Variants").First(&file, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+
+	if file.UserID == claims.UserID {
+		return &file, nil
+	}
+
+	allowed, err := HasPermission(ctx, claims.Roles, "files:read_all")
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		var count int64
+		if err := db.Model(&models.Product{}).Where("image_id = ?", file.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrFileForbidden
+		}
+	}
+
+	return &file, nil
+}
+
+// FindVariant 按名称查找文件的衍生版本，file 需通过 GetFileForUser 获取
+func FindVariant(file *models.File, name string) (*models.FileVariant, error) {
+	for i := range file.Variants {
+		if file.Variants[i].Name == name {
+			return &file.Variants[i], nil
+		}
+	}
+	return nil, ErrFileNotFound
+}
+
+// PresignObject 生成存储对象的临时下载地址，存储后端不支持预签名时返回空字符串
+func PresignObject(ctx context.Context, storedName string) (string, error) {
+	presigner, ok := fileStorage.(storage.Presigner)
+	if !ok {
+		return "", nil
+	}
+	return presigner.PresignGet(ctx, storedName, presignExpiry)
+}
+
+// OpenObject 从存储后端读取对象内容
+func OpenObject(ctx context.Context, storedName string) (io.ReadCloser, error) {
+	reader, err := fileStorage.Get(ctx, storedName)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrFileNotFound
+	}
+	return reader, err
+}
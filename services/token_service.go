@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go_core/config"
+	"go_core/models"
+	"shared/apperr"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 令牌有效期和签发者，由 InitJWTKeys 根据配置设置
+var (
+	accessTokenTTL  = 15 * time.Minute    // 访问令牌有效期
+	refreshTokenTTL = 30 * 24 * time.Hour // 刷新令牌有效期
+	tokenIssuer     = "my-gin-project"
+)
+
+var (
+	ErrInvalidRefreshToken = apperr.Unauthorized("invalid_refresh_token", "invalid or expired refresh token")
+	ErrRefreshTokenReused  = apperr.Unauthorized("refresh_token_reused", "refresh token reuse detected, session revoked")
+)
+
+// TokenPair 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌剩余秒数
+}
+
+// newOpaqueToken 生成随机的不透明令牌
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 计算令牌的 SHA-256 哈希，数据库中只保存哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createRefreshToken 在指定会话中创建一个新的刷新令牌
+func createRefreshToken(tx *gorm.DB, userID uint, familyID string) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// newTokenPair 为用户生成访问令牌，并与刷新令牌组合返回
+func newTokenPair(ctx context.Context, user models.User, familyID, refreshToken string) (*TokenPair, error) {
+	accessToken, err := GenerateToken(ctx, user, familyID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// IssueTokens 登录成功后开启一个新会话，签发访问令牌和刷新令牌
+func IssueTokens(ctx context.Context, user models.User) (*TokenPair, error) {
+	familyID, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := createRefreshToken(config.DB.WithContext(ctx), user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTokenPair(ctx, user, familyID, refreshToken)
+}
+
+// RotateRefreshToken 使用刷新令牌换取新的令牌对，旧刷新令牌随即失效
+// 如果检测到已轮换的令牌被再次使用，会撤销整个会话
+func RotateRefreshToken(ctx context.Context, raw string) (*TokenPair, error) {
+	db := config.DB.WithContext(ctx)
+
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.UsedAt != nil {
+		if err := RevokeSession(ctx, token.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	var user models.User
+	if err := db.First(&user, token.UserID).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var newRefreshToken string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 以 used_at 为空作为条件标记旧令牌，防止并发请求同时轮换
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		newRefreshToken, err = createRefreshToken(tx, token.UserID, token.FamilyID)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := RevokeSession(ctx, token.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newTokenPair(ctx, user, token.FamilyID, newRefreshToken)
+}
+
+// RevokeRefreshToken 撤销刷新令牌所属的整个会话（用于退出登录）
+func RevokeRefreshToken(ctx context.Context, raw string) error {
+	var token models.RefreshToken
+	if err := config.DB.WithContext(ctx).Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return ErrInvalidRefreshToken
+	}
+	return RevokeSession(ctx, token.FamilyID)
+}
+
+// RevokeSession 撤销会话中的所有刷新令牌
+func RevokeSession(ctx context.Context, familyID string) error {
+	return config.DB.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsSessionActive 判断会话是否仍然有效（存在未被撤销的刷新令牌）
+func IsSessionActive(ctx context.Context, familyID string) bool {
+	if familyID == "" {
+		return false
+	}
+
+	var count int64
+	err := config.DB.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Count(&count).Error
+	return err == nil && count > 0
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_core/config"
+	"go_core/models"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"shared/apperr"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultResumableUploadMaxSize 未配置路由限制时断点续传允许的最大文件大小（1GB）
+const DefaultResumableUploadMaxSize int64 = 1 << 30
+
+// uploadSettings 断点续传的配置，由 InitUploads 设置
+var uploadSettings = config.UploadConfig{SessionTTL: 24 * time.Hour}
+
+// uploadCleanupInterval 清理过期上传会话的间隔
+const uploadCleanupInterval = 10 * time.Minute
+
+var (
+	ErrUploadNotFound       = apperr.NotFound("upload_not_found", "upload not found")
+	ErrUploadExpired        = apperr.New(http.StatusGone, "upload_expired", "upload has expired")
+	ErrUploadOffsetMismatch = apperr.Conflict("upload_offset_mismatch", "upload offset does not match")
+	ErrInvalidUploadLength  = apperr.BadRequest("invalid_upload_length", "invalid upload length")
+	ErrInvalidChecksum      = apperr.BadRequest("invalid_checksum", "invalid or unsupported checksum")
+	ErrChecksumMismatch     = apperr.New(StatusChecksumMismatch, "checksum_mismatch", "checksum mismatch")
+)
+
+// StatusChecksumMismatch tus 协议约定的分块校验失败状态码
+const StatusChecksumMismatch = 460
+
+// UploadChecksumAlgorithms 分块校验支持的算法，Upload-Checksum 头的格式为 "<算法> <Base64 摘要>"
+const UploadChecksumAlgorithms = "sha1,sha256"
+
+var uploadChecksumHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// uploadSessionLocks 每个会话一把锁，保证同一会话的分块串行写入
+var uploadSessionLocks sync.Map
+
+// lockUploadSession 锁定会话，返回解锁函数
+func lockUploadSession(id string) func() {
+	value, _ := uploadSessionLocks.LoadOrStore(id, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// InitUploads 设置断点续传的临时目录和会话有效期，并启动过期会话的清理
+func InitUploads(cfg config.UploadConfig) {
+	uploadSettings = cfg
+	startUploadCleanup()
+}
+
+// uploadTempDir 保存未完成上传的目录，未配置时使用系统临时目录
+// 多副本部署时同一会话的请求需要落到同一实例，或使用共享目录
+func uploadTempDir() string {
+	if uploadSettings.TmpDir != "" {
+		return uploadSettings.TmpDir
+	}
+	return filepath.Join(os.TempDir(), "go_core_uploads")
+}
+
+// uploadSessionTTL 上传会话的有效期，每次收到分块后顺延
+func uploadSessionTTL() time.Duration {
+	return uploadSettings.SessionTTL
+}
+
+// uploadPartPath 会话临时文件的路径
+func uploadPartPath(id string) string {
+	return filepath.Join(uploadTempDir(), id+".part")
+}
+
+// CreateUploadSession 创建上传会话，checksum 为可选的完整文件 SHA-256（十六进制）
+func CreateUploadSession(ctx context.Context, userID uint, length int64, filename, checksum string, maxSize int64) (*models.UploadSession, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultResumableUploadMaxSize
+	}
+	if length <= 0 {
+		return nil, ErrInvalidUploadLength
+	}
+	if length > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	if checksum != "" {
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+			return nil, ErrInvalidChecksum
+		}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	session := models.UploadSession{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Filename:  filepath.Base(filename),
+		Length:    length,
+		Checksum:  strings.ToLower(checksum),
+		ExpiresAt: time.Now().Add(uploadSessionTTL()),
+	}
+
+	if err := os.MkdirAll(uploadTempDir(), 0o700); err != nil {
+		return nil, err
+	}
+	part, err := os.OpenFile(uploadPartPath(session.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	part.Close()
+
+	if err := config.DB.WithContext(ctx).Create(&session).Error; err != nil {
+		os.Remove(uploadPartPath(session.ID))
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetUploadSession 获取当前用户的上传会话，其他用户的会话视为不存在
+func GetUploadSession(ctx context.Context, id string, userID uint) (*models.UploadSession, error) {
+	var session models.UploadSession
+	if err := config.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if session.FileID == nil && time.Now().After(session.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+	return &session, nil
+}
+
+// WriteUploadChunk 从 offset 处写入一个分块，offset 必须等于已接收的字节数
+// checksum 为 Upload-Checksum 头，校验失败时丢弃整个分块；未提供时连接中断前收到的数据会被保留
+// 所有数据接收完成后保存文件并返回文件记录，否则返回 nil
+func WriteUploadChunk(ctx context.Context, session *models.UploadSession, offset int64, body io.Reader, checksum string) (*models.File, error) {
+	unlock := lockUploadSession(session.ID)
+	defer unlock()
+	db := config.DB.WithContext(ctx)
+
+	// 加锁后重新读取，拿到最新的偏移量
+	if err := db.First(session, "id = ?", session.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	// 已完成的会话：客户端没收到最后一次响应时重试，直接返回文件
+	if session.FileID != nil {
+		if offset != session.Length {
+			return nil, ErrUploadOffsetMismatch
+		}
+		var file models.File
+		if err := db.First(&file, *session.FileID).Error; err != nil {
+			return nil, err
+		}
+		return &file, nil
+	}
+	if offset != session.Offset {
+		return nil, ErrUploadOffsetMismatch
+	}
+
+	var digest hash.Hash
+	var expected []byte
+	if checksum != "" {
+		algorithm, encoded, _ := strings.Cut(strings.TrimSpace(checksum), " ")
+		newHash, ok := uploadChecksumHashes[strings.ToLower(algorithm)]
+		if !ok {
+			return nil, ErrInvalidChecksum
+		}
+		var err error
+		if expected, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, ErrInvalidChecksum
+		}
+		digest = newHash()
+	}
+
+	part, err := os.OpenFile(uploadPartPath(session.ID), os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+	if _, err := part.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var dst io.Writer = part
+	if digest != nil {
+		dst = io.MultiWriter(part, digest)
+	}
+	remaining := session.Length - offset
+	written, copyErr := io.Copy(dst, io.LimitReader(body, remaining+1))
+
+	// 超出声明的大小或分块校验失败时丢弃本次写入的数据
+	if written > remaining {
+		part.Truncate(offset)
+		return nil, ErrFileTooLarge
+	}
+	if digest != nil && (copyErr != nil || !bytes.Equal(digest.Sum(nil), expected)) {
+		part.Truncate(offset)
+		if copyErr != nil {
+			return nil, copyErr
+		}
+		return nil, ErrChecksumMismatch
+	}
+
+	if written > 0 {
+		session.Offset = offset + written
+		session.ExpiresAt = time.Now().Add(uploadSessionTTL())
+		// 客户端断开时请求的 ctx 已取消，仍需记录已收到的数据，以便从断点继续
+		if err := db.WithContext(context.WithoutCancel(ctx)).Model(session).Updates(map[string]interface{}{
+			"offset":     session.Offset,
+			"expires_at": session.ExpiresAt,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if copyErr != nil {
+		return nil, copyErr
+	}
+
+	if session.Offset < session.Length {
+		return nil, nil
+	}
+	return completeUpload(ctx, session)
+}
+
+// completeUpload 校验完整文件并交给 saveContent 保存，成功后删除临时文件
+// 完成的会话保留到过期，便于客户端重试最后一个分块时拿到结果
+func completeUpload(ctx context.Context, session *models.UploadSession) (*models.File, error) {
+	part, err := os.Open(uploadPartPath(session.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	if session.Checksum != "" {
+		digest := sha256.New()
+		if _, err := io.Copy(digest, part); err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(digest.Sum(nil)) != session.Checksum {
+			// 数据已损坏，只能重新上传
+			removeUploadSession(ctx, session.ID)
+			return nil, ErrChecksumMismatch
+		}
+		if _, err := part.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	// 图片要整体读入内存去除元数据，大小仍受普通上传的限制
+	imageMaxSize := int64(uploadSettings.MaxSize)
+	if imageMaxSize <= 0 {
+		imageMaxSize = DefaultUploadMaxSize
+	}
+	opts := UploadOptions{MaxSize: session.Length, ImageMaxSize: imageMaxSize}
+	file, err := saveContent(ctx, part, session.Filename, session.UserID, opts)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedFileType) || errors.Is(err, ErrFileTooLarge) {
+			removeUploadSession(ctx, session.ID)
+		}
+		return nil, err
+	}
+
+	session.FileID = &file.ID
+	if err := config.DB.WithContext(ctx).Model(session).Update("file_id", file.ID).Error; err != nil {
+		return nil, err
+	}
+	os.Remove(uploadPartPath(session.ID))
+	return file, nil
+}
+
+// CancelUploadSession 取消上传，删除会话和已接收的数据
+func CancelUploadSession(ctx context.Context, session *models.UploadSession) error {
+	unlock := lockUploadSession(session.ID)
+	defer func() {
+		unlock()
+		uploadSessionLocks.Delete(session.ID)
+	}()
+	return removeUploadSession(ctx, session.ID)
+}
+
+// removeUploadSession 删除会话记录和临时文件
+func removeUploadSession(ctx context.Context, id string) error {
+	if err := os.Remove(uploadPartPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return config.DB.WithContext(ctx).Delete(&models.UploadSession{}, "id = ?", id).Error
+}
+
+// startUploadCleanup 启动后台协程，定期删除过期的上传会话及其临时文件
+func startUploadCleanup() {
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(uploadCleanupInterval)
+		defer ticker.Stop()
+		for {
+			if err := cleanupExpiredUploads(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to clean up expired uploads", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// cleanupExpiredUploads 删除所有已过期的上传会话
+func cleanupExpiredUploads(ctx context.Context) error {
+	var ids []string
+	if err := config.DB.WithContext(ctx).Model(&models.UploadSession{}).
+		Where("expires_at < ?", time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := removeExpiredUpload(ctx, id); err != nil {
+			return fmt.Errorf("remove upload %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// removeExpiredUpload 加锁后再次确认会话已过期再删除，避免与正在写入的分块冲突
+func removeExpiredUpload(ctx context.Context, id string) error {
+	unlock := lockUploadSession(id)
+	defer func() {
+		unlock()
+		uploadSessionLocks.Delete(id)
+	}()
+
+	result := config.DB.WithContext(ctx).Where("id = ? AND expires_at < ?", id, time.Now()).Delete(&models.UploadSession{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	if err := os.Remove(uploadPartPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
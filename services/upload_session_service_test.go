@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go_core/config"
+	"go_core/models"
+	"testing"
+)
+
+// setupTestUploads 使用测试临时目录保存未完成的上传，并设置普通上传的大小限制
+func setupTestUploads(t *testing.T, maxSize int64) {
+	t.Helper()
+	previous := uploadSettings
+	uploadSettings = config.UploadConfig{TmpDir: t.TempDir(), MaxSize: config.ByteSize(maxSize)}
+	t.Cleanup(func() { uploadSettings = previous })
+}
+
+func TestResumableImageUploadIsCappedAtUploadMaxSize(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+
+	photo := testPhoto(t, 800, 600)
+	setupTestUploads(t, int64(len(photo))-1)
+
+	session, err := CreateUploadSession(ctx, user.ID, int64(len(photo)), "photo.jpg", "", 0)
+	if err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	if _, err := WriteUploadChunk(ctx, session, 0, bytes.NewReader(photo), ""); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("WriteUploadChunk error = %v, want ErrFileTooLarge", err)
+	}
+	// 无法完成的会话被删除
+	var count int64
+	config.DB.Model(&models.UploadSession{}).Where("id = ?", session.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("upload session was kept after the image was rejected")
+	}
+}
+
+func TestResumableUploadAllowsLargeNonImages(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, "owner@example.com")
+	setupTestUploads(t, 1024)
+
+	// 非图片不读入内存，只受断点续传的大小限制
+	data := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("x"), 4096)...)
+	session, err := CreateUploadSession(ctx, user.ID, int64(len(data)), "doc.pdf", "", 0)
+	if err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	file, err := WriteUploadChunk(ctx, session, 0, bytes.NewReader(data), "")
+	if err != nil {
+		t.Fatalf("WriteUploadChunk: %v", err)
+	}
+	if file == nil || file.MimeType != "application/pdf" || file.Size != int64(len(data)) {
+		t.Fatalf("file = %+v, want a %d byte PDF", file, len(data))
+	}
+}
@@ -1,85 +1,107 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"go_core/config"
 	"go_core/models"
-	"os"
+	"shared/apperr"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET")) // 使用环境变量获取密钥
+var (
+	ErrUserExists         = apperr.Conflict("user_exists", "user already exists")
+	ErrInvalidCredentials = apperr.Unauthorized("invalid_credentials", "Invalid email or password")
+	ErrInvalidToken       = apperr.Unauthorized("invalid_token", "invalid or expired token")
+)
 
 // Claims 是自定义的 JWT Claims 结构体
 type Claims struct {
-	Email string `json:"email"`
+	UserID    uint     `json:"uid"`
+	Email     string   `json:"email"`
+	SessionID string   `json:"sid"` // 所属会话，即刷新令牌的 FamilyID
+	Roles     []string `json:"roles"`
 	jwt.StandardClaims
 }
 
-// CreateUser 用于创建新用户
-func CreateUser(user models.User) error {
+// CreateUser 用于创建新用户，新用户的邮箱均为未验证
+func CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	db := config.DB.WithContext(ctx)
+
 	// 检查用户是否已存在
 	var existingUser models.User
-	if err := config.DB.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
-		return errors.New("user already exists")
+	if err := db.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
+		return nil, ErrUserExists
+	}
+
+	// 对密码进行哈希后再存储
+	hash, err := HashPassword(user.Password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hash
+	user.EmailVerifiedAt = nil
+
+	// 新用户默认授予普通用户角色
+	var defaultRole models.Role
+	if err := db.Where("name = ?", models.RoleUser).First(&defaultRole).Error; err == nil {
+		user.Roles = []models.Role{defaultRole}
 	}
 
 	// 创建新用户
-	if err := config.DB.Create(&user).Error; err != nil {
-		return err
+	if err := db.Create(&user).Error; err != nil {
+		return nil, err
 	}
-	return nil
+	return &user, nil
 }
 
 // GetUserByEmail 根据邮箱查找用户
-func GetUserByEmail(email string) (*models.User, error) {
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, errors.New("user not found")
+	if err := config.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
 	}
 	return &user, nil
 }
 
-// CheckPassword 验证密码（实际项目中应该加密存储并验证）
-func CheckPassword(storedPassword, providedPassword string) bool {
-	// 简单示例，实际应该使用密码哈希进行比较（如 bcrypt）
-	return storedPassword == providedPassword
-}
+// GenerateToken 为指定会话生成短期有效的 JWT 访问令牌
+func GenerateToken(ctx context.Context, user models.User, sessionID string) (string, error) {
+	// 将用户当前的角色写入 Token
+	roles, err := GetUserRoleNames(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
 
-// GenerateToken 生成 JWT Token
-func GenerateToken(user models.User) (string, error) {
-	// 设置 Token 过期时间为 24 小时
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(accessTokenTTL)
 	claims := &Claims{
-		Email: user.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionID,
+		Roles:     roles,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(), // 使用 Unix 时间戳表示过期时间
-			Issuer:    "my-gin-project",      // 可以设置为应用名称
+			Issuer:    tokenIssuer,
 		},
 	}
 
-	// 创建 JWT Token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// 使用密钥签名 Token
-	return token.SignedString(jwtKey)
+	// 使用当前签名密钥签发 Token
+	return signToken(claims)
 }
 
 // ValidateToken 验证 JWT Token
 func ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// 验证 Token 的签名方法是否为 HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	// 根据 kid 选择验证密钥，并校验签名算法
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
 
 	if err != nil || !token.Valid {
-		return nil, errors.New("invalid or expired token")
+		return nil, ErrInvalidToken
 	}
 
 	return claims, nil
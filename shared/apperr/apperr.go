@@ -0,0 +1,151 @@
+// Package apperr 业务错误，每个错误带有 HTTP 状态码和稳定的错误码
+// services 返回这些错误，controllers 通过 c.Error 交给 middlewares.ErrorHandler 统一输出，
+// 客户端按 code 而不是 message 判断错误类型，message 可能随版本调整
+// 通用错误的提示信息默认为英文，服务可以在启动时通过 SetMessages 替换
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 通用的错误码，具体业务可以使用更细的错误码（例如 product_not_found）
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeRequestTooLarge  = "request_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+)
+
+// Messages 通用错误的提示信息，FieldRule 和 FieldType 中的 %s 分别为校验规则和期望的类型
+type Messages struct {
+	InvalidInput    string
+	FieldRule       string
+	FieldType       string
+	RequestTooLarge string
+	RateLimited     string
+	Internal        string
+}
+
+var messages = Messages{
+	InvalidInput:    "Invalid input",
+	FieldRule:       "failed on the '%s' rule",
+	FieldType:       "must be of type %s",
+	RequestTooLarge: "Request body is too large",
+	RateLimited:     "Too many requests, please try again later",
+	Internal:        "Internal server error",
+}
+
+// SetMessages 替换通用错误的提示信息，需在处理请求之前调用
+func SetMessages(m Messages) {
+	messages = m
+}
+
+// Error 业务错误，Err 为内部原因，只写入日志，不返回给客户端
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+// FieldError 请求中某个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Body 响应中的错误信息
+type Body struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Body 转换为响应中的错误信息
+func (e *Error) Body() *Body {
+	return &Body{Code: e.Code, Message: e.Message, Details: e.Details}
+}
+
+// New 创建指定状态码的错误
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// BadRequest 请求不合法（400）
+func BadRequest(code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// Validation 请求字段校验失败（400），details 列出每个不合法的字段
+func Validation(message string, details ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Message: message, Details: details}
+}
+
+// Unauthorized 未登录或凭证无效（401）
+func Unauthorized(code, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+// Forbidden 已登录但没有权限（403）
+func Forbidden(code, message string) *Error {
+	return New(http.StatusForbidden, code, message)
+}
+
+// NotFound 资源不存在（404）
+func NotFound(code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict 与资源的当前状态冲突（409）
+func Conflict(code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// RateLimited 请求过于频繁（429）
+func RateLimited() *Error {
+	return New(http.StatusTooManyRequests, CodeRateLimited, messages.RateLimited)
+}
+
+// Internal 服务端错误（500），err 只写入日志，客户端只看到通用的提示
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: messages.Internal, Err: err}
+}
+
+// From 将任意错误转换为业务错误，不是业务错误时视为 500
+// 业务错误被 fmt.Errorf 包装过时，message 使用完整的错误信息，保留包装时添加的上下文
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		if err == error(appErr) || appErr.Status >= http.StatusInternalServerError {
+			return appErr
+		}
+		wrapped := *appErr
+		wrapped.Message = err.Error()
+		return &wrapped
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeRequestTooLarge, Message: messages.RequestTooLarge, Err: err}
+	}
+	return Internal(err)
+}
@@ -0,0 +1,37 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// InvalidInput 将 ShouldBindJSON 等绑定请求体的错误转换为业务错误
+// 字段校验失败或类型不匹配时在 details 中列出字段，请求体过大时返回 413，其余情况返回 400
+// 字段校验错误保留在 Err 中，输出时由 shared/validation.Translate 按客户端语言重新生成 details
+func InvalidInput(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, FieldError{Field: fe.Field(), Message: fmt.Sprintf(messages.FieldRule, fe.Tag())})
+		}
+		appErr := Validation(messages.InvalidInput, details...)
+		appErr.Err = err
+		return appErr
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Validation(messages.InvalidInput, FieldError{Field: typeErr.Field, Message: fmt.Sprintf(messages.FieldType, typeErr.Type.String())})
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return From(err)
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: messages.InvalidInput, Err: err}
+}
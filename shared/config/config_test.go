@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Server struct {
+		Port    int           `yaml:"port" env:"TEST_PORT" default:"8080"`
+		Timeout time.Duration `yaml:"timeout" env:"TEST_TIMEOUT" default:"10s"`
+		Proxies []string      `yaml:"proxies" env:"TEST_PROXIES"`
+	} `yaml:"server"`
+	Database struct {
+		Password string `yaml:"password" env:"TEST_DB_PASSWORD,TEST_DB_PASS" secret:"true"`
+		Migrate  bool   `yaml:"migrate" env:"TEST_DB_MIGRATE" default:"true"`
+	} `yaml:"database"`
+	Limit   Rate     `yaml:"limit" env:"TEST_LIMIT" default:"20/m"`
+	MaxSize ByteSize `yaml:"max_size" env:"TEST_MAX_SIZE" default:"10MB"`
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "server:\n  port: 9000\n  timeout: 5s\nlimit: 5/15m\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("TEST_TIMEOUT", "30s")
+	t.Setenv("TEST_PROXIES", "10.0.0.1, 10.0.0.0/8,")
+	t.Setenv("TEST_DB_PASS", "legacy")
+
+	var cfg testConfig
+	if err := Load(&cfg); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("port = %d, want 9000 from the YAML file", cfg.Server.Port)
+	}
+	if cfg.Server.Timeout != 30*time.Second {
+		t.Errorf("timeout = %s, want 30s from the environment", cfg.Server.Timeout)
+	}
+	if strings.Join(cfg.Server.Proxies, "|") != "10.0.0.1|10.0.0.0/8" {
+		t.Errorf("proxies = %q", cfg.Server.Proxies)
+	}
+	if cfg.Database.Password != "legacy" || !cfg.Database.Migrate {
+		t.Errorf("database = %+v", cfg.Database)
+	}
+	if cfg.Limit != (Rate{Limit: 5, Period: 15 * time.Minute}) || cfg.MaxSize != 10<<20 {
+		t.Errorf("limit = %v, max size = %v", cfg.Limit, cfg.MaxSize)
+	}
+
+	// 第一个有值的变量优先
+	t.Setenv("TEST_DB_PASSWORD", "current")
+	if err := Load(&cfg); err != nil || cfg.Database.Password != "current" {
+		t.Fatalf("Load = %v, password %q, want current", err, cfg.Database.Password)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  prot: 9000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CONFIG_FILE", path)
+	var cfg testConfig
+	if err := Load(&cfg); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Fatalf("Load with unknown key error = %v", err)
+	}
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("TEST_LIMIT", "fast")
+	if err := Load(&cfg); err == nil || !strings.Contains(err.Error(), "TEST_LIMIT") {
+		t.Fatalf("Load with invalid rate error = %v", err)
+	}
+}
+
+func TestFormatHidesSecrets(t *testing.T) {
+	var cfg testConfig
+	cfg.Database.Password = "hunter2"
+	cfg.Limit = Rate{Limit: 10, Period: time.Second}
+	out := Format(&cfg)
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "database.password = ******") {
+		t.Fatalf("Format leaks the secret:\n%s", out)
+	}
+	if !strings.Contains(out, "limit = 10/s") || !strings.Contains(out, "server.port = 0") {
+		t.Fatalf("Format:\n%s", out)
+	}
+}
+
+func TestRateUnmarshalText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+		ok   bool
+	}{
+		{"10/s", Rate{Limit: 10, Period: time.Second}, true},
+		{" 300 / m ", Rate{Limit: 300, Period: time.Minute}, true},
+		{"5/15m", Rate{Limit: 5, Period: 15 * time.Minute}, true},
+		{"", Rate{}, true},
+		{"0", Rate{}, true},
+		{"10", Rate{}, false},
+		{"-1/s", Rate{}, false},
+		{"10/0s", Rate{}, false},
+		{"10/fortnight", Rate{}, false},
+	}
+	for _, tt := range tests {
+		var r Rate
+		err := r.UnmarshalText([]byte(tt.in))
+		if (err == nil) != tt.ok || (tt.ok && r != tt.want) {
+			t.Errorf("UnmarshalText(%q) = %v, %v", tt.in, r, err)
+		}
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ByteSize
+		str  string
+	}{
+		{"10485760", 10 << 20, "10MB"},
+		{"512kb", 512 << 10, "512KB"},
+		{"1 GB", 1 << 30, "1GB"},
+		{"100B", 100, "100B"},
+	}
+	for _, tt := range tests {
+		var b ByteSize
+		if err := b.UnmarshalText([]byte(tt.in)); err != nil || b != tt.want || b.String() != tt.str {
+			t.Errorf("UnmarshalText(%q) = %d (%s), %v", tt.in, b, b, err)
+		}
+	}
+	var b ByteSize
+	if err := b.UnmarshalText([]byte("10XB")); err == nil {
+		t.Error("UnmarshalText(10XB): want error")
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load 按以下顺序填充 cfg（后者覆盖前者），cfg 须为指向结构体的指针：
+//  1. default 标签中的默认值
+//  2. CONFIG_FILE 指定的 YAML 文件，未知的键视为错误
+//  3. .env 和环境变量，env 标签可以列出多个变量名，按顺序取第一个有值的
+//
+// .env 不存在时只使用环境变量
+func Load(cfg any) error {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("load .env: %w", err)
+	}
+	if err := applyDefaults(cfg); err != nil {
+		return err
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadYAML(path, cfg); err != nil {
+			return err
+		}
+	}
+	return applyEnv(cfg)
+}
+
+// Format 以 "路径 = 值" 的形式输出配置，secret 标签的字段只显示是否已设置，可以安全地写入日志
+func Format(cfg any) string {
+	var lines []string
+	_ = walkFields(reflect.ValueOf(cfg).Elem(), "", func(f configField) error {
+		value := fmt.Sprint(f.value.Interface())
+		if f.tag.Get("secret") == "true" && value != "" {
+			value = "******"
+		}
+		lines = append(lines, f.path+" = "+value)
+		return nil
+	})
+	return strings.Join(lines, "\n")
+}
+
+// loadYAML 读取 YAML 配置文件，未知的键视为错误，避免拼写错误被忽略
+func loadYAML(path string, cfg any) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// configField 配置中的一个叶子字段
+type configField struct {
+	path  string // YAML 路径，例如 database.password
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// walkFields 遍历配置结构体中的所有叶子字段，实现了 encoding.TextUnmarshaler 的结构体（如 Rate）视为叶子
+func walkFields(v reflect.Value, prefix string, fn func(configField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		value := v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) && !reflect.PointerTo(field.Type).Implements(textUnmarshalerType) {
+			if err := walkFields(value, name, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(configField{path: name, value: value, tag: field.Tag}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDefaults 写入 default 标签中的默认值
+func applyDefaults(cfg any) error {
+	return walkFields(reflect.ValueOf(cfg).Elem(), "", func(f configField) error {
+		if value, ok := f.tag.Lookup("default"); ok {
+			if err := setField(f.value, value); err != nil {
+				return fmt.Errorf("default for %s: %w", f.path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// applyEnv 用环境变量覆盖配置
+func applyEnv(cfg any) error {
+	return walkFields(reflect.ValueOf(cfg).Elem(), "", func(f configField) error {
+		for _, name := range strings.Split(f.tag.Get("env"), ",") {
+			if name == "" {
+				continue
+			}
+			if value, ok := os.LookupEnv(name); ok && value != "" {
+				if err := setField(f.value, value); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// setField 将字符串解析为字段的类型，切片按逗号分隔
+func setField(v reflect.Value, raw string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", raw)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setField(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package config 各服务共用的配置加载：default 标签、CONFIG_FILE 指定的 YAML 文件、.env 和环境变量
+// 以及配置中常用的 ByteSize、Rate 类型
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize 字节数，配置中可写为 10485760、512KB、10MB 或 1GB
+type ByteSize int64
+
+// UnmarshalText 解析带单位的大小
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	value := strings.ToUpper(strings.TrimSpace(string(text)))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", text)
+	}
+	*b = ByteSize(n * multiplier)
+	return nil
+}
+
+// UnmarshalYAML 让 YAML 中的数字和带单位的字符串都能解析
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	return b.UnmarshalText([]byte(node.Value))
+}
+
+// String 以最大的整数单位显示
+func (b ByteSize) String() string {
+	switch {
+	case b != 0 && b%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", b>>30)
+	case b != 0 && b%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", b>>20)
+	case b != 0 && b%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", b>>10)
+	default:
+		return fmt.Sprintf("%dB", int64(b))
+	}
+}
+
+// Rate 限流速率，写为 "<次数>/<周期>"，例如 10/s、300/m、5/15m，为空或 0 表示不限流
+// 次数同时是允许的突发请求数，周期内匀速补满
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// UnmarshalText 解析 "<次数>/<周期>"，周期省略数字时按 1 个单位计算
+func (r *Rate) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	if value == "" || value == "0" {
+		*r = Rate{}
+		return nil
+	}
+	count, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("invalid rate %q, expected <count>/<period>", text)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid rate %q", text)
+	}
+	period = strings.TrimSpace(period)
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid rate period %q", text)
+	}
+	*r = Rate{Limit: n, Period: d}
+	return nil
+}
+
+// UnmarshalYAML 让 YAML 中的速率可以直接写为 10/s
+func (r *Rate) UnmarshalYAML(node *yaml.Node) error {
+	return r.UnmarshalText([]byte(node.Value))
+}
+
+// Enabled 次数为 0 时不限流
+func (r Rate) Enabled() bool {
+	return r.Limit > 0
+}
+
+// String 以 "<次数>/<周期>" 显示
+func (r Rate) String() string {
+	if !r.Enabled() {
+		return "0"
+	}
+	switch r.Period {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.Limit)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.Limit)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.Limit)
+	default:
+		return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+	}
+}
@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ready 实例是否接收新流量，收到退出信号后先置为 false，让负载均衡摘除实例
+var ready atomic.Bool
+
+// Ready 实例是否就绪，/readyz 据此返回 200 或 503
+func Ready() bool {
+	return ready.Load()
+}
+
+// Config 退出过程的时间设置
+type Config struct {
+	ShutdownDelay   time.Duration // 收到退出信号后先标记为未就绪，等待负载均衡摘除实例
+	ShutdownTimeout time.Duration // 等待进行中的请求完成和执行清理函数各自的最长时间
+}
+
+// shutdownHook 退出时执行的清理函数
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+var (
+	hooksMu sync.Mutex
+	hooks   []shutdownHook
+)
+
+// OnShutdown 注册退出时执行的清理函数，按注册的逆序执行：先启动的组件（如数据库连接池）最后关闭
+func OnShutdown(name string, fn func(ctx context.Context) error) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Serve 启动 HTTP 服务并阻塞，收到 SIGINT 或 SIGTERM 后按以下顺序退出：
+//  1. 标记为未就绪，等待 ShutdownDelay，让负载均衡停止转发新请求
+//  2. 停止监听，等待进行中的请求完成，最长 ShutdownTimeout
+//  3. 按逆序执行 OnShutdown 注册的清理函数，同样最长 ShutdownTimeout
+//
+// 退出过程中再次收到信号会立即终止进程
+func Serve(srv *http.Server, cfg Config) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	ready.Store(true)
+	slog.Info("Server listening", "addr", srv.Addr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		// 监听失败（例如端口被占用），仍然释放已初始化的资源
+		ready.Store(false)
+		runHooks(cfg.ShutdownTimeout)
+		return err
+	case <-ctx.Done():
+	}
+	// 恢复默认的信号处理，再次收到信号时直接退出
+	stop()
+
+	slog.Info("Shutdown signal received, draining")
+	ready.Store(false)
+	if cfg.ShutdownDelay > 0 {
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Warn("Server did not drain in time, closing remaining connections", "timeout", cfg.ShutdownTimeout.String(), "error", err)
+		srv.Close()
+	}
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = serveErr
+	}
+
+	runHooks(cfg.ShutdownTimeout)
+	slog.Info("Server stopped")
+	return err
+}
+
+// runHooks 按注册的逆序执行清理函数，单个失败不影响其他
+func runHooks(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil {
+			slog.Error("Failed to stop "+hooks[i].name, "error", err)
+		}
+	}
+	hooks = nil
+}
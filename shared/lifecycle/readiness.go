@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"shared/logging"
+	"sync"
+	"time"
+)
+
+// readinessCheckTimeout 单项依赖检查的超时时间，需小于探针的超时时间
+const readinessCheckTimeout = 2 * time.Second
+
+// 检查结果的状态
+const (
+	CheckOK   = "ok"
+	CheckFail = "fail"
+)
+
+// CheckResult 单项依赖的检查结果，接口未鉴权，失败原因只记录到日志，不返回给调用方
+type CheckResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+var (
+	checksMu sync.Mutex
+	checks   = map[string]func(ctx context.Context) error{}
+)
+
+// OnReadiness 注册就绪检查依赖的服务，同名检查后注册的覆盖先注册的
+func OnReadiness(name string, check func(ctx context.Context) error) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks[name] = check
+}
+
+// CheckReadiness 并发执行 OnReadiness 注册的检查，每项最长 readinessCheckTimeout，全部通过时返回 true
+func CheckReadiness(ctx context.Context) (bool, map[string]CheckResult) {
+	checksMu.Lock()
+	registered := make(map[string]func(ctx context.Context) error, len(checks))
+	for name, check := range checks {
+		registered[name] = check
+	}
+	checksMu.Unlock()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]CheckResult, len(registered))
+	)
+	for name, check := range registered {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			result := CheckResult{Status: CheckOK, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = CheckFail
+				logging.FromContext(ctx).Warn("Readiness check failed", "check", name, "latency_ms", result.LatencyMS, "error", err)
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Status != CheckOK {
+			return false, results
+		}
+	}
+	return true, results
+}
@@ -0,0 +1,80 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// setChecks 替换已注册的检查，测试结束后恢复
+func setChecks(t *testing.T, registered map[string]func(ctx context.Context) error) {
+	t.Helper()
+	checksMu.Lock()
+	saved := checks
+	checks = registered
+	checksMu.Unlock()
+	t.Cleanup(func() {
+		checksMu.Lock()
+		checks = saved
+		checksMu.Unlock()
+	})
+}
+
+func TestCheckReadiness(t *testing.T) {
+	pass := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("connection refused") }
+	tests := []struct {
+		name   string
+		checks map[string]func(ctx context.Context) error
+		ok     bool
+		want   map[string]string
+	}{
+		{"no checks", map[string]func(ctx context.Context) error{}, true, map[string]string{}},
+		{"all pass", map[string]func(ctx context.Context) error{"database": pass, "storage": pass}, true,
+			map[string]string{"database": CheckOK, "storage": CheckOK}},
+		{"one fails", map[string]func(ctx context.Context) error{"database": pass, "storage": fail}, false,
+			map[string]string{"database": CheckOK, "storage": CheckFail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setChecks(t, tt.checks)
+			ok, results := CheckReadiness(context.Background())
+			if ok != tt.ok || len(results) != len(tt.want) {
+				t.Fatalf("CheckReadiness = %v, %v; want %v", ok, results, tt.ok)
+			}
+			for name, status := range tt.want {
+				if results[name].Status != status {
+					t.Errorf("%s = %s, want %s", name, results[name].Status, status)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckReadinessTimeout(t *testing.T) {
+	setChecks(t, map[string]func(ctx context.Context) error{
+		"database": func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("no deadline")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	// 调用方取消时挂起的检查随之结束
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ok, results := CheckReadiness(ctx)
+	if ok || results["database"].Status != CheckFail {
+		t.Fatalf("CheckReadiness = %v, %v; want database to fail", ok, results)
+	}
+}
+
+func TestOnReadinessReplaces(t *testing.T) {
+	setChecks(t, map[string]func(ctx context.Context) error{})
+	OnReadiness("database", func(ctx context.Context) error { return errors.New("down") })
+	OnReadiness("database", func(ctx context.Context) error { return nil })
+	if ok, _ := CheckReadiness(context.Background()); !ok {
+		t.Fatal("CheckReadiness: want the later check to replace the earlier one")
+	}
+}
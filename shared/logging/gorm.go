@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// gormLogger 将 GORM 的日志写入 context 中的 logger，查询日志因此带有 request_id
+// 普通查询记为 debug，超过 slowThreshold 的查询记为 warn，出错的查询记为 error
+type gormLogger struct {
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewGormLogger 创建 GORM 日志适配器，slowThreshold 为 0 时不记录慢查询
+func NewGormLogger(slowThreshold time.Duration) gormlogger.Interface {
+	return &gormLogger{level: gormlogger.Info, slowThreshold: slowThreshold}
+}
+
+// LogMode 实现 gormlogger.Interface，db.Debug() 等会通过它调整级别
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// ParamsFilter 实现 gorm.ParamsFilter，日志中的 SQL 只保留占位符，不记录密码哈希、令牌等参数值
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+// Trace 每条 SQL 执行后调用，查询不到记录（ErrRecordNotFound）属于正常结果，不视为错误
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	logger := FromContext(ctx)
+	elapsed := time.Since(begin)
+	var (
+		level slog.Level
+		msg   string
+	)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		level, msg = slog.LevelError, "Database query failed"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		level, msg = slog.LevelWarn, "Slow database query"
+	case l.level >= gormlogger.Info:
+		level, msg = slog.LevelDebug, "Database query"
+	default:
+		return
+	}
+	// 生成 SQL 需要拼接参数，级别未开启时跳过
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{"sql", sql, "rows", rows, "duration_ms", float64(elapsed.Microseconds()) / 1000}
+	if level == slog.LevelError {
+		attrs = append(attrs, "error", err)
+	}
+	logger.Log(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency by operation and table.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms ~ 4s
+	}, []string{"operation", "table"})
+
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Total number of failed database queries by operation and table. Record not found is not counted.",
+	}, []string{"operation", "table"})
+)
+
+// startTimeKey 查询开始时间在 Statement 中的键
+const startTimeKey = "metrics:start_time"
+
+// InstrumentDB 注册 GORM 回调记录每次查询的耗时和错误，并导出连接池状态
+// dbName 用于连接池指标的 db_name 标签
+func InstrumentDB(db *gorm.DB, dbName string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := prometheus.Register(collectors.NewDBStatsCollector(sqlDB, dbName)); err != nil {
+		return err
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("metrics:before_create", beforeQuery),
+		cb.Create().After("*").Register("metrics:after_create", afterQuery("create")),
+		cb.Query().Before("*").Register("metrics:before_query", beforeQuery),
+		cb.Query().After("*").Register("metrics:after_query", afterQuery("query")),
+		cb.Update().Before("*").Register("metrics:before_update", beforeQuery),
+		cb.Update().After("*").Register("metrics:after_update", afterQuery("update")),
+		cb.Delete().Before("*").Register("metrics:before_delete", beforeQuery),
+		cb.Delete().After("*").Register("metrics:after_delete", afterQuery("delete")),
+		cb.Row().Before("*").Register("metrics:before_row", beforeQuery),
+		cb.Row().After("*").Register("metrics:after_row", afterQuery("row")),
+		cb.Raw().Before("*").Register("metrics:before_raw", beforeQuery),
+		cb.Raw().After("*").Register("metrics:after_raw", afterQuery("raw")),
+	)
+}
+
+// beforeQuery 记录查询开始时间
+func beforeQuery(db *gorm.DB) {
+	db.InstanceSet(startTimeKey, time.Now())
+}
+
+// afterQuery 记录查询耗时，出错时计数，ErrRecordNotFound 属于正常结果
+func afterQuery(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startTimeKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			dbQueryErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package metrics Prometheus 指标，通过单独端口上的 /metrics 暴露
+// HTTP 指标按路由模板（例如 /api/products/:id）而不是实际路径统计，避免标签数量无限增长
+package metrics
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Number of HTTP requests currently being served.",
+	})
+)
+
+// UnmatchedRoute 未匹配到路由的请求（404）使用的路由标签
+const UnmatchedRoute = "unmatched"
+
+// OtherMethod 非标准 HTTP 方法使用的方法标签
+const OtherMethod = "OTHER"
+
+// standardMethods 按原样记录的 HTTP 方法，方法名由客户端决定，其他值统一记为 OtherMethod
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Serve 在 addr 上单独提供 /metrics，不挂在业务路由上，对外暴露业务端口时指标不会随之公开
+// 监听失败时立即返回错误，之后在后台处理请求，退出时调用返回的 Server 的 Shutdown
+func Serve(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Addr: listener.Addr().String(), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server stopped", "error", err)
+		}
+	}()
+	slog.Info("Metrics server listening", "addr", srv.Addr)
+	return srv, nil
+}
+
+// methodLabel 请求方法的标签值，非标准方法记为 OtherMethod，避免客户端构造任意方法名使标签数量无限增长
+func methodLabel(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return OtherMethod
+}
+
+// RequestStarted 记录开始处理一个请求，返回的函数在请求完成后调用
+func RequestStarted() func(method, route string, status int) {
+	start := time.Now()
+	httpRequestsInFlight.Inc()
+	return func(method, route string, status int) {
+		httpRequestsInFlight.Dec()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		labels := prometheus.Labels{"method": methodLabel(method), "route": route, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
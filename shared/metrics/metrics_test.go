@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMethodLabel(t *testing.T) {
+	for _, method := range []string{"GET", "POST", "DELETE", "OPTIONS"} {
+		RequestStarted()(method, "/method-label", http.StatusOK)
+		if got := testutil.ToFloat64(httpRequests.WithLabelValues(method, "/method-label", "200")); got != 1 {
+			t.Fatalf("%s requests = %v, want 1", method, got)
+		}
+	}
+
+	// 非标准方法合并为 OTHER
+	for _, method := range []string{"PROPFIND", "FOO", "get", strings.Repeat("X", 100)} {
+		RequestStarted()(method, "/method-label", http.StatusMethodNotAllowed)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues(OtherMethod, "/method-label", "405")); got != 4 {
+		t.Fatalf("OTHER requests = %v, want 4", got)
+	}
+	if n := testutil.CollectAndCount(httpRequests); n != 5 {
+		t.Fatalf("%d label combinations, want 5", n)
+	}
+}
+
+func TestServe(t *testing.T) {
+	srv, err := Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + srv.Addr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "http_requests_in_flight") {
+		t.Fatalf("GET /metrics = %d\n%s", resp.StatusCode, body)
+	}
+
+	// 只提供 /metrics
+	resp, err = http.Get("http://" + srv.Addr + "/api/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET /api/users = %d, want 404", resp.StatusCode)
+	}
+
+	if _, err := Serve(srv.Addr); err == nil {
+		t.Fatal("Serve on a used address succeeded")
+	}
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"shared/apperr"
+	"shared/logging"
+	"shared/response"
+	"shared/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler 将 handlers 通过 c.Error 记录的最后一个错误转换为统一的错误响应，需放在 Recovery 之后
+// 已经写入响应体时不再处理；5xx 的内部原因只写入日志，由 Logger 记录
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		abortWithError(c, c.Errors.Last().Err)
+	}
+}
+
+// NotFound 未匹配到路由时返回统一格式的 404，message 为返回给客户端的提示
+func NotFound(message string) gin.HandlerFunc {
+	errRouteNotFound := apperr.NotFound("route_not_found", message)
+	return func(c *gin.Context) {
+		c.Error(errRouteNotFound)
+	}
+}
+
+// abortWithError 输出错误响应，响应中带有 request_id 便于排查；HEAD 请求只返回状态码
+// 字段校验错误按 Accept-Language 输出中文或英文信息
+func abortWithError(c *gin.Context, err error) {
+	appErr := apperr.From(err)
+	if c.Request.Method == http.MethodHead {
+		c.AbortWithStatus(appErr.Status)
+		return
+	}
+	body := appErr.Body()
+	if details, ok := validation.Translate(appErr.Err, c.GetHeader("Accept-Language")); ok {
+		body.Details = details
+	}
+	body.RequestID = logging.RequestID(c.Request.Context())
+	c.AbortWithStatusJSON(appErr.Status, response.NewError(body))
+}
+
+// Recovery 捕获 panic，记录堆栈并返回 500，替代 gin 默认输出到 stderr 的 Recovery
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).ErrorContext(ctx, "Panic recovered", "error", err, "stack", string(debug.Stack()))
+		abortWithError(c, apperr.Internal(fmt.Errorf("panic: %v", err)))
+	})
+}
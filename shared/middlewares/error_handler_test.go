@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/response"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newErrorRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery(), ErrorHandler())
+	router.NoRoute(NotFound("Route not found"))
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return router
+}
+
+func TestErrorHandler(t *testing.T) {
+	router := newErrorRouter()
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/missing", http.StatusNotFound, "route_not_found"},
+		{http.MethodGet, "/panic", http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			var resp response.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode body %q: %v", w.Body.String(), err)
+			}
+			if resp.Error == nil || resp.Error.Code != tt.code || resp.Error.RequestID == "" {
+				t.Fatalf("error = %+v, want code %s with request_id", resp.Error, tt.code)
+			}
+			if resp.Error.RequestID != w.Header().Get(RequestIDHeader) {
+				t.Fatalf("request_id = %q, header %q", resp.Error.RequestID, w.Header().Get(RequestIDHeader))
+			}
+		})
+	}
+}
+
+func TestErrorHandlerHead(t *testing.T) {
+	w := httptest.NewRecorder()
+	newErrorRouter().ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Body.Len() != 0 {
+		t.Fatalf("HEAD: status %d, body %q", w.Code, w.Body.String())
+	}
+}
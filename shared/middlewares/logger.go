@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"shared/logging"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogAttrsFunc 返回请求日志中额外记录的字段，例如登录的用户
+type LogAttrsFunc func(c *gin.Context) []any
+
+// Logger 请求完成后记录一条结构化日志，需放在 RequestID 之后，attrs 为 nil 时只记录通用字段
+// 5xx 记为 error，4xx 记为 warn，其余记为 info；WebSocket 连接在断开后才记录
+func Logger(attrs LogAttrsFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		ctx := c.Request.Context()
+		path := c.Request.URL.Path
+
+		// 处理请求
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []any{
+			"method", c.Request.Method,
+			"path", path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+			"bytes", max(c.Writer.Size(), 0), // 未写入响应体时为 -1
+		}
+		if attrs != nil {
+			fields = append(fields, attrs(c)...)
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		logging.FromContext(ctx).Log(ctx, level, "Request completed", fields...)
+	}
+}
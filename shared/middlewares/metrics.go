@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"shared/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录请求数量和耗时，按路由模板和状态码统计，需放在 Recovery 之前以统计 panic 导致的 500
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := metrics.RequestStarted()
+		c.Next()
+		done(c.Request.Method, c.FullPath(), c.Writer.Status())
+	}
+}
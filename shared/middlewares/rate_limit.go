@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"shared/apperr"
+	"shared/logging"
+	"shared/ratelimit"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKeyFunc 返回请求的计数身份
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// ClientIPKey 按客户端 IP 计数，只采信 TRUSTED_PROXIES 转发的 X-Forwarded-For
+func ClientIPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimit 按路由组和计数身份限流，group 区分不同路由组的令牌桶
+// 每个响应都带有 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 和 RateLimit-Policy，
+// 超过限制时返回 429 和 Retry-After；存储不可用时放行请求并记录日志，避免 Redis 故障导致整个服务不可用
+func RateLimit(store ratelimit.Store, group string, limit ratelimit.Limit, key RateLimitKeyFunc) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	policy := strconv.Itoa(limit.Burst) + ";w=" + strconv.Itoa(ceilSeconds(limit.Period))
+	return func(c *gin.Context) {
+		result, err := store.Take(c.Request.Context(), group+":"+key(c), limit, time.Now())
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("Rate limit store unavailable, allowing request", "group", group, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Header("RateLimit-Policy", policy)
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.Error(apperr.RateLimited())
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ceilSeconds 向上取整的秒数，响应头中的时间都以整秒表示
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"shared/apperr"
+	"shared/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRateLimitRouter(store ratelimit.Store, limit ratelimit.Limit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// 与各服务的 ErrorHandler 一样，按 c.Error 记录的错误输出状态码
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			appErr := apperr.From(c.Errors.Last().Err)
+			c.AbortWithStatusJSON(appErr.Status, appErr.Body())
+		}
+	})
+	router.GET("/ping", RateLimit(store, "api", limit, ClientIPKey), func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	return router
+}
+
+func get(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	stores := map[string]ratelimit.Store{"memory": ratelimit.NewMemory(), "redis": ratelimit.NewRedis(client)}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			// 每 30 秒补充一个令牌
+			router := newRateLimitRouter(store, ratelimit.Limit{Burst: 2, Period: time.Minute})
+			for i, want := range []struct{ remaining, reset string }{{"1", "30"}, {"0", "60"}} {
+				w := get(router, "192.0.2.1:1234")
+				if w.Code != http.StatusOK {
+					t.Fatalf("request %d: status %d", i, w.Code)
+				}
+				header := w.Header()
+				if header.Get("RateLimit-Limit") != "2" || header.Get("RateLimit-Remaining") != want.remaining ||
+					header.Get("RateLimit-Reset") != want.reset || header.Get("RateLimit-Policy") != "2;w=60" {
+					t.Fatalf("request %d: headers %v", i, header)
+				}
+				if header.Get("Retry-After") != "" {
+					t.Fatalf("request %d: Retry-After set on an allowed request", i)
+				}
+			}
+
+			w := get(router, "192.0.2.1:1234")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("third request: status %d, want 429", w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != "30" {
+				t.Fatalf("Retry-After = %q, want 30", got)
+			}
+			if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+				t.Fatalf("RateLimit-Remaining = %q, want 0", got)
+			}
+
+			// 其他客户端不受影响
+			if w := get(router, "192.0.2.2:1234"); w.Code != http.StatusOK {
+				t.Fatalf("other client: status %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimitAllowsRequestsWhenStoreIsDown(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+	server.Close()
+
+	router := newRateLimitRouter(ratelimit.NewRedis(client), ratelimit.Limit{Burst: 1, Period: time.Minute})
+	for i := 0; i < 3; i++ {
+		w := get(router, "192.0.2.1:1234")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("request %d: rate limit headers set without a store", i)
+		}
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	router := newRateLimitRouter(ratelimit.NewMemory(), ratelimit.Limit{})
+	for i := 0; i < 5; i++ {
+		if w := get(router, "192.0.2.1:1234"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("request %d: status %d, headers %v", i, w.Code, w.Header())
+		}
+	}
+}
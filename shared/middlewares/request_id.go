@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"shared/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 接受的客户端请求 ID 的最大长度
+const maxRequestIDLength = 128
+
+// RequestID 沿用客户端或网关传入的 X-Request-ID，没有或格式不合法时生成新的 ID
+// ID 写入响应头，并将带有 request_id 字段的 logger 放入请求的 context，需放在其他中间件之前
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// isValidRequestID 只接受可见 ASCII 字符，避免日志注入
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成 32 位十六进制的随机 ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Dir 迁移文件所在的目录，create 命令在此生成新文件
+const Dir = "migrations"
+
+// Usage migrate 子命令的用法
+const Usage = `usage: migrate <command> [args]
+
+commands:
+  up            apply all pending migrations
+  down [n]      roll back the last n applied migrations (default 1)
+  status        show which migrations have been applied
+  create <name> generate a new migration file in ./migrations
+`
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Run 执行 migrate 子命令，connect 只在需要访问数据库时调用
+func Run(args []string, connect func() (*gorm.DB, error), out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(Usage)
+		}
+		path, err := Create(Dir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\n", path)
+		return nil
+	}
+
+	var steps int
+	switch args[0] {
+	case "up", "status":
+		if len(args) != 1 {
+			return errors.New(Usage)
+		}
+	case "down":
+		steps = 1
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid number of migrations to roll back %q", args[1])
+			}
+			steps = n
+		} else if len(args) > 2 {
+			return errors.New(Usage)
+		}
+	default:
+		return errors.New(Usage)
+	}
+
+	db, err := connect()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return Up(db)
+	case "down":
+		return Down(db, steps)
+	default:
+		return printStatus(db, out)
+	}
+}
+
+// printStatus 以表格形式输出迁移状态
+func printStatus(db *gorm.DB, out io.Writer) error {
+	list, err := Status(db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range list {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format(time.RFC3339)
+		}
+		if status.Missing {
+			appliedAt += " (missing in this binary)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+// migrationTemplate 新迁移文件的模板
+const migrationTemplate = `package migrations
+
+import (
+	"shared/migrate"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	migrate.Register(migrate.Migration{
+		Version: %d,
+		Name:    %q,
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`
+
+// Create 在 dir 下生成以当前 UTC 时间为版本号的迁移文件，返回文件路径
+func Create(dir, name string) (string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+	}
+
+	version, err := strconv.ParseInt(time.Now().UTC().Format("20060102150405"), 10, 64)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d_%s.go", version, name))
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, migrationTemplate, version, name); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,319 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockName 迁移锁的名称，多个副本同时启动时只有一个执行迁移
+// MySQL 的锁在整个数据库服务器内共享，使用同一个服务器的服务需要通过 SetLockName 设置不同的名称
+var lockName = "schema_migrations"
+
+// lockTimeout 等待其他副本完成迁移的最长时间
+var lockTimeout = 10 * time.Minute
+
+// lockPollInterval 等待迁移锁时的轮询间隔
+var lockPollInterval = time.Second
+
+// lockQuery 获取和释放迁移锁的语句
+type lockQuery struct {
+	try     string // 尝试获取锁，不阻塞，返回是否成功
+	release string
+	key     func() interface{}
+}
+
+// lockQueries 支持会话级锁的数据库，键为 gorm 方言名称
+// SQLite 没有会话级的锁，但 DDL 可以在事务中执行：
+// 写事务由 SQLite 串行化，另一个进程重复执行同一迁移时会因 schema_migrations 主键冲突而整体回滚
+var lockQueries = map[string]lockQuery{
+	"mysql": {
+		try:     "SELECT GET_LOCK(?, 0)",
+		release: "SELECT RELEASE_LOCK(?)",
+		key:     func() interface{} { return lockName },
+	},
+	"postgres": {
+		try:     "SELECT pg_try_advisory_lock(?)",
+		release: "SELECT pg_advisory_unlock(?)",
+		key:     func() interface{} { return lockKey() },
+	},
+}
+
+var (
+	ErrIrreversible   = errors.New("migration cannot be rolled back")
+	ErrUnknownVersion = errors.New("applied migration is not registered in this binary")
+	ErrLockTimeout    = errors.New("timed out waiting for the migration lock")
+)
+
+// SetLockName 设置迁移锁的名称，需在执行迁移之前调用
+func SetLockName(name string) {
+	lockName = name
+}
+
+// Migration 一次版本化的数据库变更
+// Up 和 Down 只能使用传入的 tx，不能使用 config.DB，否则不在同一个事务和连接中
+// 注意 MySQL 的 DDL 会隐式提交事务，失败时已执行的 DDL 不会回滚，一个迁移最好只做一件事
+type Migration struct {
+	Version int64  // 版本号，使用创建时的 UTC 时间 YYYYMMDDHHMMSS
+	Name    string // 例如 add_users_email_index
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为 nil 时表示不可回滚
+}
+
+// String 迁移的完整名称，与文件名一致
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// registry 已注册的迁移，由 migrations 包中各文件的 init 注册
+var registry = map[int64]Migration{}
+
+// Register 注册迁移，版本号重复时 panic
+func Register(m Migration) {
+	if m.Up == nil {
+		panic(fmt.Sprintf("migrate: migration %s has no Up", m))
+	}
+	if existing, ok := registry[m.Version]; ok {
+		panic(fmt.Sprintf("migrate: duplicate version %d (%s and %s)", m.Version, existing, m))
+	}
+	registry[m.Version] = m
+}
+
+// migrations 按版本号升序返回已注册的迁移
+func migrations() []Migration {
+	list := make([]Migration, 0, len(registry))
+	for _, m := range registry {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// schemaMigration schema_migrations 表，记录已执行的迁移
+type schemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Up 按版本号顺序执行所有未执行的迁移，每个迁移在单独的事务中执行
+func Up(db *gorm.DB) error {
+	return withLock(db, func(conn *gorm.DB) error {
+		if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations() {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			slog.Info("Applying migration", "migration", m.String())
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本号倒序回滚最近执行的 steps 个迁移
+func Down(db *gorm.DB, steps int) error {
+	return withLock(db, func(conn *gorm.DB) error {
+		if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, version := range versions {
+			m, ok := registry[version]
+			if !ok {
+				return fmt.Errorf("%w: %d_%s", ErrUnknownVersion, version, applied[version].Name)
+			}
+			if m.Down == nil {
+				return fmt.Errorf("%w: %s", ErrIrreversible, m)
+			}
+			slog.Info("Rolling back migration", "migration", m.String())
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %s: %w", m, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatus 迁移的执行状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // 未执行时为 nil
+	Missing   bool       // 数据库中已执行，但当前程序中没有该迁移（通常是程序版本旧于数据库）
+}
+
+// Status 返回所有迁移的执行状态，按版本号升序
+// 只读取 schema_migrations 表，不创建表，可以在就绪检查中频繁调用
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	applied := map[int64]schemaMigration{}
+	if db.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		if applied, err = appliedMigrations(db); err != nil {
+			return nil, err
+		}
+	}
+
+	var list []MigrationStatus
+	for _, m := range migrations() {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if record, ok := applied[m.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		list = append(list, status)
+	}
+	for version, record := range applied {
+		if _, ok := registry[version]; !ok {
+			appliedAt := record.AppliedAt
+			list = append(list, MigrationStatus{Version: version, Name: record.Name, AppliedAt: &appliedAt, Missing: true})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Pending 返回尚未执行的迁移
+func Pending(db *gorm.DB) ([]Migration, error) {
+	list, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range list {
+		if status.AppliedAt == nil {
+			pending = append(pending, registry[status.Version])
+		}
+	}
+	return pending, nil
+}
+
+// CheckApplied 所有迁移都已执行时返回 nil，否则返回列出未执行迁移的错误，用于就绪检查
+// 关闭自动迁移时，新版本可能先于 migrate up 部署，此时实例不应接收流量
+func CheckApplied(db *gorm.DB) error {
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	names := make([]string, len(pending))
+	for i, m := range pending {
+		names[i] = m.String()
+	}
+	return fmt.Errorf("%d pending migrations: %s", len(pending), strings.Join(names, ", "))
+}
+
+// appliedMigrations 读取已执行的迁移
+func appliedMigrations(db *gorm.DB) (map[int64]schemaMigration, error) {
+	var records []schemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// withLock 在同一个连接上持有迁移锁并执行 fn
+// MySQL 和 PostgreSQL 的锁属于会话，必须在同一个连接上加锁、迁移和解锁
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		// Connection 返回的实例会在链式调用间共享 Statement，需要开启新的会话
+		conn = conn.Session(&gorm.Session{})
+		unlock, err := acquireLock(conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return fn(conn)
+	})
+}
+
+// acquireLock 获取迁移锁，其他副本持有锁时等待其完成
+func acquireLock(conn *gorm.DB) (func(), error) {
+	query, ok := lockQueries[conn.Dialector.Name()]
+	if !ok {
+		slog.Debug("Database has no session lock, running migrations without it", "dialect", conn.Dialector.Name())
+		return func() {}, nil
+	}
+	arg := query.key()
+
+	deadline := time.Now().Add(lockTimeout)
+	for waiting := false; ; waiting = true {
+		var locked bool
+		if err := conn.Raw(query.try, arg).Row().Scan(&locked); err != nil {
+			return nil, fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLockTimeout
+		}
+		if !waiting {
+			slog.Info("Another instance is running migrations, waiting for the lock")
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	return func() {
+		if err := conn.Exec(query.release, arg).Error; err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+	}, nil
+}
+
+// lockKey PostgreSQL 的 advisory lock 使用整数作为键，由锁名称计算
+func lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(lockName))
+	return int64(h.Sum64())
+}
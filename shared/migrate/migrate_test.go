@@ -0,0 +1,306 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testLocks 模拟 MySQL GET_LOCK 的会话锁，通过 SQL 函数注册到测试用的 SQLite 驱动
+var testLocks = struct {
+	sync.Mutex
+	held map[string]bool
+}{held: map[string]bool{}}
+
+func testTryLock(name string) bool {
+	testLocks.Lock()
+	defer testLocks.Unlock()
+	if testLocks.held[name] {
+		return false
+	}
+	testLocks.held[name] = true
+	return true
+}
+
+func testReleaseLock(name string) bool {
+	testLocks.Lock()
+	defer testLocks.Unlock()
+	held := testLocks.held[name]
+	delete(testLocks.held, name)
+	return held
+}
+
+func init() {
+	sql.Register("sqlite3_migrate_test", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("test_get_lock", testTryLock, false); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("test_release_lock", testReleaseLock, false)
+		},
+	})
+}
+
+// setupDB 打开临时 SQLite 数据库，并清空已注册的迁移
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.New(sqlite.Config{
+		DriverName: "sqlite3_migrate_test",
+		DSN:        filepath.Join(t.TempDir(), "migrate.db"),
+	}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	saved := registry
+	registry = map[int64]Migration{}
+	t.Cleanup(func() { registry = saved })
+	return db
+}
+
+// useSQLiteLock 让 SQLite 走会话锁的流程，锁由 test_get_lock / test_release_lock 实现
+func useSQLiteLock(t *testing.T) {
+	t.Helper()
+	lockQueries["sqlite"] = lockQuery{
+		try:     "SELECT test_get_lock(?)",
+		release: "SELECT test_release_lock(?)",
+		key:     func() interface{} { return lockName },
+	}
+	savedTimeout, savedInterval := lockTimeout, lockPollInterval
+	lockPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		delete(lockQueries, "sqlite")
+		lockTimeout, lockPollInterval = savedTimeout, savedInterval
+	})
+}
+
+// createTable 创建只有一个 id 列的表的迁移
+func createTable(version int64, table string, calls *[]int64) Migration {
+	return Migration{
+		Version: version,
+		Name:    "create_" + table,
+		Up: func(tx *gorm.DB) error {
+			*calls = append(*calls, version)
+			return tx.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE " + table).Error
+		},
+	}
+}
+
+func appliedVersions(t *testing.T, db *gorm.DB) []int64 {
+	t.Helper()
+	var versions []int64
+	if err := db.Model(&schemaMigration{}).Order("version").Pluck("version", &versions).Error; err != nil {
+		t.Fatalf("read schema_migrations: %v", err)
+	}
+	return versions
+}
+
+func equalVersions(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpAppliesInVersionOrder(t *testing.T) {
+	db := setupDB(t)
+	var calls []int64
+	Register(createTable(3, "c", &calls))
+	Register(createTable(1, "a", &calls))
+	Register(createTable(2, "b", &calls))
+
+	if err := Up(db); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if want := []int64{1, 2, 3}; !equalVersions(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if want := []int64{1, 2, 3}; !equalVersions(appliedVersions(t, db), want) {
+		t.Errorf("applied = %v, want %v", appliedVersions(t, db), want)
+	}
+	if err := CheckApplied(db); err != nil {
+		t.Errorf("CheckApplied: %v", err)
+	}
+}
+
+func TestUpSkipsAppliedVersions(t *testing.T) {
+	db := setupDB(t)
+	var calls []int64
+	Register(createTable(1, "a", &calls))
+	Register(createTable(2, "b", &calls))
+
+	if err := Up(db); err != nil {
+		t.Fatalf("first Up: %v", err)
+	}
+	if err := Up(db); err != nil {
+		t.Fatalf("second Up: %v", err)
+	}
+	if want := []int64{1, 2}; !equalVersions(calls, want) {
+		t.Fatalf("calls after repeated Up = %v, want %v", calls, want)
+	}
+
+	// 新版本程序带来的迁移只执行新增的部分
+	Register(createTable(3, "c", &calls))
+	if err := CheckApplied(db); err == nil {
+		t.Error("CheckApplied: expected pending migration error")
+	}
+	if err := Up(db); err != nil {
+		t.Fatalf("third Up: %v", err)
+	}
+	if want := []int64{1, 2, 3}; !equalVersions(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestUpRollsBackFailedMigration(t *testing.T) {
+	db := setupDB(t)
+	var calls []int64
+	failure := errors.New("boom")
+	Register(createTable(1, "a", &calls))
+	Register(Migration{
+		Version: 2,
+		Name:    "half_done",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE b (id INTEGER PRIMARY KEY)").Error; err != nil {
+				return err
+			}
+			return failure
+		},
+	})
+	Register(createTable(3, "c", &calls))
+
+	err := Up(db)
+	if !errors.Is(err, failure) {
+		t.Fatalf("Up err = %v, want %v", err, failure)
+	}
+	if db.Migrator().HasTable("b") {
+		t.Error("table created by the failed migration was not rolled back")
+	}
+	if want := []int64{1}; !equalVersions(appliedVersions(t, db), want) {
+		t.Errorf("applied = %v, want %v", appliedVersions(t, db), want)
+	}
+	if want := []int64{1}; !equalVersions(calls, want) {
+		t.Errorf("migrations after the failure ran: calls = %v", calls)
+	}
+
+	pending, err := Pending(db)
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Version != 2 || pending[1].Version != 3 {
+		t.Errorf("pending = %v, want versions 2 and 3", pending)
+	}
+}
+
+func TestDownRollsBackLatest(t *testing.T) {
+	db := setupDB(t)
+	var calls []int64
+	Register(createTable(1, "a", &calls))
+	Register(createTable(2, "b", &calls))
+	Register(Migration{Version: 3, Name: "irreversible", Up: func(tx *gorm.DB) error { return nil }})
+
+	if err := Up(db); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := Down(db, 1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("Down irreversible err = %v, want ErrIrreversible", err)
+	}
+
+	delete(registry, 3)
+	if err := Down(db, 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("Down unknown err = %v, want ErrUnknownVersion", err)
+	}
+	if err := db.Delete(&schemaMigration{}, 3).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Down(db, 1); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if db.Migrator().HasTable("b") || !db.Migrator().HasTable("a") {
+		t.Error("Down did not roll back only the latest migration")
+	}
+	if want := []int64{1}; !equalVersions(appliedVersions(t, db), want) {
+		t.Errorf("applied = %v, want %v", appliedVersions(t, db), want)
+	}
+}
+
+func TestUpWaitsForLock(t *testing.T) {
+	db := setupDB(t)
+	useSQLiteLock(t)
+	var calls []int64
+	Register(createTable(1, "a", &calls))
+
+	// 模拟另一个副本正在迁移
+	if !testTryLock(lockName) {
+		t.Fatal("lock already held")
+	}
+	done := make(chan error, 1)
+	go func() { done <- Up(db) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Up returned while the lock was held: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	testReleaseLock(lockName)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Up did not acquire the released lock")
+	}
+	if want := []int64{1}; !equalVersions(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// 迁移结束后锁被释放
+	if !testTryLock(lockName) {
+		t.Fatal("Up did not release the lock")
+	}
+	testReleaseLock(lockName)
+}
+
+func TestUpLockTimeout(t *testing.T) {
+	db := setupDB(t)
+	useSQLiteLock(t)
+	lockTimeout = 50 * time.Millisecond
+	var calls []int64
+	Register(createTable(1, "a", &calls))
+
+	if !testTryLock(lockName) {
+		t.Fatal("lock already held")
+	}
+	defer testReleaseLock(lockName)
+
+	if err := Up(db); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Up err = %v, want ErrLockTimeout", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("migrations ran without the lock: %v", calls)
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limit 令牌桶参数：桶的容量为 Burst，每个 Period 匀速补满
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Enabled 容量或周期为 0 时不限流
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Period > 0
+}
+
+// perSecond 每秒补充的令牌数
+func (l Limit) perSecond() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Result 一次取令牌的结果，用于设置 RateLimit-* 响应头
+type Result struct {
+	Allowed    bool
+	Limit      int           // 桶的容量
+	Remaining  int           // 取完后剩余的整数令牌
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌的时间
+	Reset      time.Duration // 距离桶补满的时间
+}
+
+// newResult 根据取令牌之后桶中的令牌数计算结果
+func newResult(limit Limit, allowed bool, tokens float64) Result {
+	rate := limit.perSecond()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store 令牌桶的存储，key 由调用方区分路由组和身份，例如 "auth:ip:127.0.0.1"
+type Store interface {
+	// Take 从 key 对应的桶中取一个令牌，桶不存在时视为满的
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+	// Close 释放存储占用的连接
+	Close() error
+}
+
+// Config 存储配置
+type Config struct {
+	Driver   string // memory（默认）或 redis
+	RedisURL string // redis 驱动的地址，例如 redis://:password@localhost:6379/0
+}
+
+// New 根据配置创建存储
+func New(cfg Config) (Store, error) {
+	switch cfg.Driver {
+	case "", "memory":
+		return NewMemory(), nil
+	case "redis":
+		return NewRedisFromURL(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store %q", cfg.Driver)
+	}
+}
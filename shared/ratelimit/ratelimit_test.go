@@ -0,0 +1,213 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis 创建指向进程内 miniredis 的存储
+func newTestRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedis(client), server
+}
+
+// stores 每个测试都对两种存储各运行一次
+func stores(t *testing.T) map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemory() },
+		"redis": func(t *testing.T) Store {
+			store, _ := newTestRedis(t)
+			return store
+		},
+	}
+}
+
+// approx 比较时间，Redis 中按毫秒计算，允许 1ms 的误差
+func approx(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -time.Millisecond && diff < time.Millisecond
+}
+
+type take struct {
+	at         time.Duration // 相对起始时间
+	key        string
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+func runTakes(t *testing.T, store Store, limit Limit, takes []take) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, tt := range takes {
+		key := tt.key
+		if key == "" {
+			key = "api:ip:127.0.0.1"
+		}
+		result, err := store.Take(context.Background(), key, limit, start.Add(tt.at))
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+		if result.Allowed != tt.allowed || result.Remaining != tt.remaining || result.Limit != limit.Burst {
+			t.Fatalf("take %d at %v: allowed=%v remaining=%d limit=%d, want allowed=%v remaining=%d limit=%d",
+				i, tt.at, result.Allowed, result.Remaining, result.Limit, tt.allowed, tt.remaining, limit.Burst)
+		}
+		if !approx(result.RetryAfter, tt.retryAfter) || !approx(result.Reset, tt.reset) {
+			t.Fatalf("take %d at %v: retryAfter=%v reset=%v, want retryAfter=%v reset=%v",
+				i, tt.at, result.RetryAfter, result.Reset, tt.retryAfter, tt.reset)
+		}
+	}
+}
+
+func TestBurstAndRefill(t *testing.T) {
+	// 容量 3，每秒补充 1 个令牌
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			runTakes(t, newStore(t), limit, []take{
+				{at: 0, allowed: true, remaining: 2, reset: time.Second},
+				{at: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{at: 0, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{at: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+				// 半个令牌不够，还要再等 500ms
+				{at: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, reset: 2500 * time.Millisecond},
+				{at: time.Second, allowed: true, remaining: 0, reset: 3 * time.Second},
+				// 其他 key 使用独立的桶
+				{at: time.Second, key: "api:ip:10.0.0.1", allowed: true, remaining: 2, reset: time.Second},
+				// 长时间没有请求后最多补满到容量
+				{at: time.Minute, allowed: true, remaining: 2, reset: time.Second},
+			})
+		})
+	}
+}
+
+func TestFractionalTokensArePreserved(t *testing.T) {
+	// 每 400ms 补充一个令牌，补充的小数部分要跨请求保留
+	limit := Limit{Burst: 5, Period: 2 * time.Second}
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			takes := make([]take, 0, 9)
+			for i := 5; i > 0; i-- {
+				takes = append(takes, take{allowed: true, remaining: i - 1, reset: time.Duration(6-i) * 400 * time.Millisecond})
+			}
+			takes = append(takes,
+				take{at: 300 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 100 * time.Millisecond, reset: 1700 * time.Millisecond},
+				take{at: 600 * time.Millisecond, allowed: true, remaining: 0, reset: 1800 * time.Millisecond},
+				// 剩余的 0.5 个令牌加上 300ms 补充的 0.75 个
+				take{at: 900 * time.Millisecond, allowed: true, remaining: 0, reset: 1900 * time.Millisecond},
+				take{at: 950 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 250 * time.Millisecond, reset: 1850 * time.Millisecond},
+			)
+			runTakes(t, newStore(t), limit, takes)
+		})
+	}
+}
+
+func TestClockGoingBackwardsDoesNotAddTokens(t *testing.T) {
+	limit := Limit{Burst: 1, Period: time.Second}
+	for name, newStore := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			runTakes(t, newStore(t), limit, []take{
+				{at: time.Second, allowed: true, remaining: 0, reset: time.Second},
+				// 其他副本的时钟慢了 500ms
+				{at: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: time.Second, reset: time.Second},
+				{at: 2 * time.Second, allowed: true, remaining: 0, reset: time.Second},
+			})
+		})
+	}
+}
+
+func TestRedisKeyExpiresWhenBucketIsFull(t *testing.T) {
+	store, server := newTestRedis(t)
+	limit := Limit{Burst: 4, Period: 2 * time.Second}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if _, err := store.Take(context.Background(), "auth:ip:1.2.3.4", limit, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := redisKeyPrefix + "auth:ip:1.2.3.4"
+	if !server.Exists(key) {
+		t.Fatalf("key %q was not created", key)
+	}
+	if got := server.HGet(key, "tokens"); got != "1" {
+		t.Fatalf("tokens = %q, want 1", got)
+	}
+	// 3 个令牌每 500ms 补一个，1500ms 后补满
+	if ttl := server.TTL(key); ttl != 1501*time.Millisecond {
+		t.Fatalf("TTL = %v, want 1.501s", ttl)
+	}
+
+	server.FastForward(1501 * time.Millisecond)
+	if server.Exists(key) {
+		t.Fatal("key did not expire after the bucket was full")
+	}
+	result, err := store.Take(context.Background(), "auth:ip:1.2.3.4", limit, now.Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("after expiry: allowed=%v remaining=%d, want a full bucket", result.Allowed, result.Remaining)
+	}
+}
+
+func TestRedisErrorIsReturned(t *testing.T) {
+	store, server := newTestRedis(t)
+	server.Close()
+	if _, err := store.Take(context.Background(), "k", Limit{Burst: 1, Period: time.Second}, time.Now()); err == nil {
+		t.Fatal("Take succeeded with Redis down")
+	}
+}
+
+func TestMemorySweepsFullBuckets(t *testing.T) {
+	store := NewMemory()
+	limit := Limit{Burst: 2, Period: 2 * time.Minute}
+	start := time.Now()
+
+	store.Take(context.Background(), "old", limit, start)
+	store.Take(context.Background(), "recent", limit, start.Add(90*time.Second))
+	// 下次清理时 old 已经一个周期没有请求
+	store.Take(context.Background(), "new", limit, start.Add(150*time.Second))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.buckets["old"]; ok {
+		t.Fatal("full bucket was not swept")
+	}
+	if _, ok := store.buckets["recent"]; !ok {
+		t.Fatal("bucket that is still refilling was swept")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if store, err := New(Config{}); err != nil {
+		t.Fatal(err)
+	} else if _, ok := store.(*Memory); !ok {
+		t.Fatalf("default store is %T, want *Memory", store)
+	}
+
+	server := miniredis.RunT(t)
+	store, err := New(Config{Driver: "redis", RedisURL: "redis://" + server.Addr() + "/0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if result, err := store.Take(context.Background(), "k", Limit{Burst: 1, Period: time.Second}, time.Now()); err != nil || !result.Allowed {
+		t.Fatalf("Take = %+v, %v", result, err)
+	}
+
+	if _, err := New(Config{Driver: "redis"}); err == nil {
+		t.Fatal("redis store without a URL succeeded")
+	}
+	if _, err := New(Config{Driver: "memcached"}); err == nil {
+		t.Fatal("unsupported store succeeded")
+	}
+}
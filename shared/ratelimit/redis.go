@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix Redis 中令牌桶 key 的前缀
+const redisKeyPrefix = "ratelimit:"
+
+// takeScript 在 Redis 中原子地补充并取出令牌，桶保存为 hash {tokens, ts}
+// 时间由调用方传入（毫秒），各副本的时钟偏差只影响补充速度；补满所需的时间后 key 自动过期
+// 令牌数以字符串返回，避免 Lua 数字转换为 Redis 整数时丢失小数
+var takeScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local rate = burst / period
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+	ts = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis 存储，多个副本共享同一份计数，兼容 Redis 协议的服务（如 Valkey、KeyDB）均可使用
+// 测试时可以把 client 指向进程内的 Redis 替身（如 miniredis）
+type Redis struct {
+	client redis.Scripter
+	closer func() error
+}
+
+// NewRedis 使用已有的客户端创建存储，客户端由调用方关闭
+func NewRedis(client redis.Scripter) *Redis {
+	return &Redis{client: client, closer: func() error { return nil }}
+}
+
+// NewRedisFromURL 根据 redis:// 或 rediss:// 地址创建客户端和存储
+func NewRedisFromURL(url string) (*Redis, error) {
+	if url == "" {
+		return nil, fmt.Errorf("rate limit store redis requires a URL")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	return &Redis{client: client, closer: client.Close}, nil
+}
+
+func (r *Redis) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	values, err := takeScript.Run(ctx, r.client,
+		[]string{redisKeyPrefix + key},
+		limit.Burst, limit.Period.Milliseconds(), now.UnixMilli(),
+	).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	allowed, _ := values[0].(int64)
+	raw, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected rate limit tokens %q", raw)
+	}
+	return newResult(limit, allowed == 1, tokens), nil
+}
+
+func (r *Redis) Close() error {
+	return r.closer()
+}
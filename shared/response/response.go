@@ -0,0 +1,27 @@
+// Package response 各服务统一的响应结构
+package response
+
+import "shared/apperr"
+
+// Response 统一的响应结构：成功时返回 data，可附带 message；失败时只返回 error
+// 错误响应由 shared/middlewares.ErrorHandler 生成，handlers 只需调用 c.Error
+type Response struct {
+	Message string       `json:"message,omitempty"` // 操作结果的提示
+	Data    interface{}  `json:"data,omitempty"`    // 响应的数据
+	Error   *apperr.Body `json:"error,omitempty"`   // 错误信息
+}
+
+// NewSuccess 创建一个成功的响应
+func NewSuccess(data interface{}) *Response {
+	return &Response{Data: data}
+}
+
+// NewMessage 创建一个带提示的成功响应，data 可以为 nil
+func NewMessage(message string, data interface{}) *Response {
+	return &Response{Message: message, Data: data}
+}
+
+// NewError 创建一个错误的响应
+func NewError(body *apperr.Body) *Response {
+	return &Response{Error: body}
+}
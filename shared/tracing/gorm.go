@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey 查询的 span 在 Statement 中的键
+const spanKey = "tracing:span"
+
+// InstrumentDB 注册 GORM 回调，为每条语句创建 client span
+// 只在 context 中已有链路时记录（即通过 WithContext 传入了请求的 context），启动迁移和后台任务的查询不单独成链
+func InstrumentDB(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("tracing:before_create", beforeStatement("create")),
+		cb.Create().After("*").Register("tracing:after_create", afterStatement),
+		cb.Query().Before("*").Register("tracing:before_query", beforeStatement("query")),
+		cb.Query().After("*").Register("tracing:after_query", afterStatement),
+		cb.Update().Before("*").Register("tracing:before_update", beforeStatement("update")),
+		cb.Update().After("*").Register("tracing:after_update", afterStatement),
+		cb.Delete().Before("*").Register("tracing:before_delete", beforeStatement("delete")),
+		cb.Delete().After("*").Register("tracing:after_delete", afterStatement),
+		cb.Row().Before("*").Register("tracing:before_row", beforeStatement("row")),
+		cb.Row().After("*").Register("tracing:after_row", afterStatement),
+		cb.Raw().Before("*").Register("tracing:before_raw", beforeStatement("raw")),
+		cb.Raw().After("*").Register("tracing:after_raw", afterStatement),
+	)
+}
+
+// beforeStatement 创建 span，表名在执行前已由 GORM 解析
+func beforeStatement(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		name := operation
+		if table := db.Statement.Table; table != "" {
+			name += " " + table
+		}
+		_, span := Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// afterStatement 记录 SQL（只有占位符，不含参数值）和影响行数后结束 span，ErrRecordNotFound 属于正常结果
+func afterStatement(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
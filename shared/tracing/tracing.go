@@ -0,0 +1,100 @@
+// Package tracing OpenTelemetry 链路追踪
+// 请求的 span 由 middlewares.Tracing 创建，上游通过 W3C traceparent 头传入的链路会被延续，
+// GORM 查询和对外的 HTTP 请求作为子 span 记录在同一条链路中
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"shared/buildinfo"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config 链路追踪配置
+type Config struct {
+	Exporter    string  // none、stdout 或 otlp，none 时只传播上游的 trace 上下文
+	Endpoint    string  // OTLP/HTTP 的完整地址，为空时由 SDK 读取 OTEL_EXPORTER_OTLP_ENDPOINT
+	ServiceName string  // 服务名称，同时作为本服务创建的 span 的来源名称
+	SampleRatio float64 // 新链路的采样比例，上游已采样的请求始终记录
+}
+
+// instrumentationName 本服务创建的 span 的来源名称，由 Init 设置为服务名称
+var instrumentationName = "shared/tracing"
+
+// Init 启用 W3C traceparent 和 baggage 的传播，并按配置创建全局的 TracerProvider
+// 返回的函数在退出时调用，导出缓冲中剩余的 span；Exporter 为 none 时不创建 TracerProvider
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.ServiceName != "" {
+		instrumentationName = cfg.ServiceName
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if cfg.Exporter == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(buildinfo.Get().Version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create tracing resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	switch cfg.Exporter {
+	case "stdout":
+		// 本地调试时逐个输出，不等待批量
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("create stdout exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	case "otlp":
+		var exporterOpts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("create otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q", cfg.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer 返回本服务使用的 Tracer，Init 之前返回的 Tracer 在 Init 之后同样生效，但来源名称不是服务名称
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Transport 包装 base，为对外的 HTTP 请求创建 client span 并注入 traceparent 头
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
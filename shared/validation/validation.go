@@ -0,0 +1,135 @@
+// Package validation 请求参数校验引擎，在 gin 的 validator 上注册中英文错误信息和各服务的自定义规则
+// 模型和请求体通过 binding 标签声明规则，ShouldBindJSON 校验失败时由错误处理中间件
+// 调用 Translate 按 Accept-Language 生成每个字段的错误信息
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"shared/apperr"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// uni 各语言的翻译器，未匹配到客户端语言时使用 Setup 指定的默认语言
+var uni *ut.UniversalTranslator
+
+// Rule 自定义校验规则及其中英文错误信息，{0} 为字段名，{1} 为规则参数
+type Rule struct {
+	Tag  string
+	Func validator.Func
+	En   string
+	Zh   string
+}
+
+// Setup 注册字段名、默认翻译和服务自己的规则，需在处理请求前调用一次
+// fallback 为客户端语言不受支持时使用的语言，可选 "en" 或 "zh"
+func Setup(fallback string, rules ...Rule) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	// 错误信息中使用 JSON 字段名，与请求体保持一致
+	v.RegisterTagNameFunc(jsonFieldName)
+
+	enLocale, zhLocale := en.New(), zh.New()
+	var fallbackLocale locales.Translator
+	switch fallback {
+	case "en":
+		fallbackLocale = enLocale
+	case "zh":
+		fallbackLocale = zhLocale
+	default:
+		return fmt.Errorf("unsupported fallback language %q", fallback)
+	}
+	uni = ut.New(fallbackLocale, enLocale, zhLocale)
+	enTrans, _ := uni.GetTranslator("en")
+	zhTrans, _ := uni.GetTranslator("zh")
+	if err := enTranslations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return fmt.Errorf("register en translations: %w", err)
+	}
+	if err := zhTranslations.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		return fmt.Errorf("register zh translations: %w", err)
+	}
+
+	for _, rule := range rules {
+		if err := v.RegisterValidation(rule.Tag, rule.Func); err != nil {
+			return fmt.Errorf("register %s: %w", rule.Tag, err)
+		}
+		if err := registerTranslation(v, enTrans, rule.Tag, rule.En); err != nil {
+			return err
+		}
+		if err := registerTranslation(v, zhTrans, rule.Tag, rule.Zh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Translate 将绑定错误中的字段校验错误翻译为客户端语言，err 中没有字段校验错误时返回 false
+func Translate(err error, acceptLanguage string) ([]apperr.FieldError, bool) {
+	var validationErrs validator.ValidationErrors
+	if uni == nil || !errors.As(err, &validationErrs) {
+		return nil, false
+	}
+
+	trans, _ := uni.FindTranslator(languages(acceptLanguage)...)
+	details := make([]apperr.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		details = append(details, apperr.FieldError{Field: fe.Field(), Message: fe.Translate(trans)})
+	}
+	return details, true
+}
+
+// languages 按出现顺序返回 Accept-Language 中的语言（忽略地区和权重），例如 "zh-CN,en;q=0.8" 返回 zh、en
+func languages(acceptLanguage string) []string {
+	var langs []string
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+		if lang = strings.ToLower(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// jsonFieldName 返回字段的 JSON 名称，json:"-" 的字段不出现在请求体中
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// registerTranslation 为自定义规则注册一种语言的错误信息
+func registerTranslation(v *validator.Validate, trans ut.Translator, tag, text string) error {
+	register := func(t ut.Translator) error {
+		return t.Add(tag, text, true)
+	}
+	translate := func(t ut.Translator, fe validator.FieldError) string {
+		msg, err := t.T(tag, fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return msg
+	}
+	if err := v.RegisterTranslation(tag, trans, register, translate); err != nil {
+		return fmt.Errorf("register %s translation: %w", tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package validation
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestMain(m *testing.M) {
+	even := Rule{
+		Tag:  "even",
+		Func: func(fl validator.FieldLevel) bool { return fl.Field().Int()%2 == 0 },
+		En:   "{0} must be even",
+		Zh:   "{0}必须为偶数",
+	}
+	if err := Setup("zh", even); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestTranslate(t *testing.T) {
+	req := struct {
+		Count int    `json:"count" binding:"even"`
+		Name  string `json:"name" binding:"required"`
+	}{Count: 3}
+	err := binding.Validator.ValidateStruct(&req)
+	if err == nil {
+		t.Fatal("ValidateStruct: want error")
+	}
+
+	tests := []struct {
+		acceptLanguage string
+		want           []string
+	}{
+		{"en-US,en;q=0.9", []string{"count must be even", "name is a required field"}},
+		{"zh-CN", []string{"count必须为偶数", "name为必填字段"}},
+		{"fr", []string{"count必须为偶数", "name为必填字段"}}, // 不支持的语言使用默认语言
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			details, ok := Translate(err, tt.acceptLanguage)
+			if !ok || len(details) != len(tt.want) {
+				t.Fatalf("Translate = %v, %v", details, ok)
+			}
+			for i, want := range tt.want {
+				if details[i].Message != want {
+					t.Errorf("details[%d] = %q, want %q", i, details[i].Message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTranslateIgnoresOtherErrors(t *testing.T) {
+	if _, ok := Translate(os.ErrNotExist, "en"); ok {
+		t.Fatal("Translate: want false for non-validation error")
+	}
+}
+
+func TestSetupRejectsUnknownFallback(t *testing.T) {
+	if err := Setup("fr"); err == nil {
+		t.Fatal("Setup: want error for unsupported fallback")
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	got := languages("zh-CN,en;q=0.8, FR ;q=0.5")
+	want := []string{"zh", "en", "fr"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("languages = %v, want %v", got, want)
+	}
+}
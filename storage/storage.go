@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Backend 文件存储后端，key 为对象名称（例如 "<sha256>.jpg"）
+type Backend interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists 判断对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete 删除对象，不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// Ping 检查后端是否可用，用于就绪检查
+	Ping(ctx context.Context) error
+}
+
+// Presigner 支持生成预签名下载地址的后端实现该接口
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Config 存储后端配置
+type Config struct {
+	Driver   string // local（默认）、s3 或 memory（仅用于测试）
+	LocalDir string // 本地存储目录，默认 ./uploads
+	S3       S3Config
+}
+
+// New 根据配置创建存储后端
+func New(cfg Config) (Backend, error) {
+	switch cfg.Driver {
+	case "", "local":
+		dir := cfg.LocalDir
+		if dir == "" {
+			dir = "./uploads"
+		}
+		return NewLocal(dir)
+	case "s3":
+		return NewS3(cfg.S3)
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage driver %q", cfg.Driver)
+	}
+}
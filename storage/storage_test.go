@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testBackend 所有后端都应满足的 Backend 行为
+func testBackend(t *testing.T, backend Backend) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := backend.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	key := "0123abcd.jpg"
+	if exists, err := backend.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists before Put = %v, %v; want false", exists, err)
+	}
+	if _, err := backend.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put error = %v, want ErrNotFound", err)
+	}
+
+	put := func(content string) {
+		t.Helper()
+		if err := backend.Put(ctx, key, strings.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	get := func() string {
+		t.Helper()
+		r, err := backend.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return string(data)
+	}
+
+	put("first")
+	if exists, err := backend.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists after Put = %v, %v; want true", exists, err)
+	}
+	if got := get(); got != "first" {
+		t.Fatalf("Get = %q, want %q", got, "first")
+	}
+
+	// 已存在时覆盖
+	put("second version")
+	if got := get(); got != "second version" {
+		t.Fatalf("Get after overwrite = %q", got)
+	}
+
+	// 二进制内容原样保存
+	binary := bytes.Repeat([]byte{0, 0xff, '\n', 0x80}, 4096)
+	if err := backend.Put(ctx, "nested/blob.bin", bytes.NewReader(binary), int64(len(binary)), "application/octet-stream"); err != nil {
+		t.Fatalf("Put nested: %v", err)
+	}
+	r, err := backend.Get(ctx, "nested/blob.bin")
+	if err != nil {
+		t.Fatalf("Get nested: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(data, binary) {
+		t.Fatalf("nested object is %d bytes, want %d", len(data), len(binary))
+	}
+
+	if err := backend.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if exists, err := backend.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v; want false", exists, err)
+	}
+	if _, err := backend.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	// 删除不存在的对象不返回错误
+	if err := backend.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+
+	if err := backend.Put(ctx, "", strings.NewReader("x"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("Put with empty key error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, NewMemory())
+}
+
+func TestLocal(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	backend, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	testBackend(t, backend)
+}
+
+func TestLocalRejectsPathTraversal(t *testing.T) {
+	backend, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"..", "../secret", "a/../../secret", "/etc/passwd"} {
+		if err := backend.Put(ctx, key, strings.NewReader("x"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := backend.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+	// 清理后仍在目录内的路径是允许的
+	if err := backend.Put(ctx, "a/../b.txt", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatalf("Put(a/../b.txt): %v", err)
+	}
+	if exists, _ := backend.Exists(ctx, "b.txt"); !exists {
+		t.Fatal("a/../b.txt was not stored as b.txt")
+	}
+}
+
+func TestLocalPingFailsForMissingDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	backend, err := NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	backend.dir = filepath.Join(dir, "missing")
+	if err := backend.Ping(context.Background()); err == nil {
+		t.Fatal("Ping succeeded for a missing directory")
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{cfg: Config{LocalDir: dir}, want: "*storage.Local"},
+		{cfg: Config{Driver: "local", LocalDir: dir}, want: "*storage.Local"},
+		{cfg: Config{Driver: "memory"}, want: "*storage.Memory"},
+		{cfg: Config{Driver: "s3", S3: S3Config{Endpoint: "http://localhost:9000", Bucket: "b", AccessKey: "a", SecretKey: "s"}}, want: "*storage.S3"},
+		{cfg: Config{Driver: "s3"}, wantErr: true},
+		{cfg: Config{Driver: "ftp"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		backend, err := New(tt.cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q) succeeded, want error", tt.cfg.Driver)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q): %v", tt.cfg.Driver, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", backend); got != tt.want {
+			t.Errorf("New(%q) = %s, want %s", tt.cfg.Driver, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	sortpkg "sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 游标的翻页方向
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// cursorPayload 游标中保存的内容，签名后以不透明字符串返回给客户端
+type cursorPayload struct {
+	Spec      string        `json:"s"` // 生成游标时排序和过滤条件的哈希，更换排序或过滤条件后旧游标失效
+	Direction string        `json:"d"`
+	Values    []interface{} `json:"v"` // 按排序字段顺序保存的边界行的值
+}
+
+var (
+	cursorSecretOnce sync.Once
+	cursorSecret     []byte
+)
+
+// SetCursorSecret 设置游标签名密钥，启动时根据配置调用
+func SetCursorSecret(secret string) {
+	if secret != "" {
+		cursorSecret = []byte(secret)
+	}
+}
+
+// getCursorSecret 返回游标签名密钥，未配置时随机生成
+// 随机密钥只在当前进程内有效，多副本部署时必须配置
+func getCursorSecret() []byte {
+	cursorSecretOnce.Do(func() {
+		if cursorSecret == nil {
+			cursorSecret = make([]byte, 32)
+			_, _ = rand.Read(cursorSecret)
+		}
+	})
+	return cursorSecret
+}
+
+// encodeCursor 序列化并签名游标
+func encodeCursor(payload cursorPayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, getCursorSecret())
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(data) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// decodeCursor 校验签名并解析游标
+func decodeCursor(token string) (cursorPayload, error) {
+	var payload cursorPayload
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return payload, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return payload, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return payload, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	mac := hmac.New(sha256.New, getCursorSecret())
+	mac.Write(data)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return payload, fmt.Errorf("%w: invalid cursor signature", ErrInvalidQuery)
+	}
+
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return payload, nil
+}
+
+// keysetSorts 返回游标分页使用的排序，末尾追加唯一键保证顺序稳定
+func (s QuerySpec) keysetSorts() []SortField {
+	sorts := append([]SortField{}, s.Sorts...)
+	for _, sort := range sorts {
+		if sort.Name == s.options.KeyField {
+			return sorts
+		}
+	}
+	key := s.options.Sorts[s.options.KeyField]
+	return append(sorts, SortField{Name: s.options.KeyField, Column: key.Column})
+}
+
+// specHash 排序和过滤条件的哈希，写入游标用于校验
+// 游标中的边界值只在同一排序和过滤条件下有意义，换用其他条件会跳过或重复记录
+func (s QuerySpec) specHash(sorts []SortField) string {
+	var b strings.Builder
+	for _, sort := range sorts {
+		direction := "asc"
+		if sort.Desc {
+			direction = "desc"
+		}
+		fmt.Fprintf(&b, "sort=%s:%s\n", sort.Name, direction)
+	}
+	fmt.Fprintf(&b, "q=%q\n", s.Search)
+	// Ranges 按 map 遍历生成，顺序不固定，按列名排序后再计算
+	ranges := append([]RangeFilter{}, s.Ranges...)
+	sortpkg.Slice(ranges, func(i, j int) bool { return ranges[i].Column < ranges[j].Column })
+	for _, r := range ranges {
+		fmt.Fprintf(&b, "range=%s:%s:%s\n", r.Column, rangeBound(r.Min), rangeBound(r.Max))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// rangeBound 范围边界的规范表示，时间统一为 UTC
+func rangeBound(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ApplyKeyset 按游标追加 WHERE、ORDER BY 和 LIMIT，替代 OFFSET 分页
+// 会多查询一条记录用于判断是否还有下一页，查询后需调用 FinishKeyset
+func (s QuerySpec) ApplyKeyset(db *gorm.DB, p *Pagination) (*gorm.DB, error) {
+	sorts := s.keysetSorts()
+	p.direction = cursorNext
+
+	if p.Cursor != "" {
+		payload, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if payload.Spec != s.specHash(sorts) || len(payload.Values) != len(sorts) {
+			return nil, fmt.Errorf("%w: cursor does not match sort or filters", ErrInvalidQuery)
+		}
+		if payload.Direction != cursorNext && payload.Direction != cursorPrev {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		p.direction = payload.Direction
+
+		values, err := s.decodeCursorValues(sorts, payload.Values)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(keysetCondition(sorts, values, p.direction == cursorPrev))
+	}
+
+	// 向前翻页时反向排序，取到结果后再翻转
+	for _, sort := range sorts {
+		desc := sort.Desc != (p.direction == cursorPrev)
+		if desc {
+			db = db.Order(sort.Column + " DESC")
+		} else {
+			db = db.Order(sort.Column + " ASC")
+		}
+	}
+
+	return db.Limit(p.PageSize + 1), nil
+}
+
+// keysetCondition 生成 (a > ?) OR (a = ? AND b > ?) ... 形式的条件
+func keysetCondition(sorts []SortField, values []interface{}, backward bool) clause.Expr {
+	var conditions []string
+	var args []interface{}
+	for i, sort := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, sorts[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if sort.Desc != backward {
+			op = "<"
+		}
+		parts = append(parts, sort.Column+" "+op+" ?")
+		args = append(args, values[i])
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(conditions, " OR ") + ")", Vars: args}
+}
+
+// decodeCursorValues 将游标中的 JSON 值还原为查询参数，时间字段解析为 time.Time
+func (s QuerySpec) decodeCursorValues(sorts []SortField, raw []interface{}) ([]interface{}, error) {
+	values := make([]interface{}, len(raw))
+	for i, sort := range sorts {
+		values[i] = raw[i]
+		if s.options.Sorts[sort.Name].Type != RangeTime {
+			continue
+		}
+		text, ok := raw[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		values[i] = t
+	}
+	return values, nil
+}
+
+// FinishKeyset 处理游标分页的查询结果：去掉多查的一条、恢复顺序并生成前后页游标
+// items 必须是指向切片的指针
+func (s QuerySpec) FinishKeyset(items interface{}, p *Pagination) error {
+	slice := reflect.ValueOf(items).Elem()
+
+	hasMore := slice.Len() > p.PageSize
+	if hasMore {
+		slice.Set(slice.Slice(0, p.PageSize))
+	}
+
+	backward := p.direction == cursorPrev
+	if backward {
+		for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+			a, b := slice.Index(i).Interface(), slice.Index(j).Interface()
+			slice.Index(i).Set(reflect.ValueOf(b))
+			slice.Index(j).Set(reflect.ValueOf(a))
+		}
+	}
+
+	if slice.Len() == 0 {
+		return nil
+	}
+
+	sorts := s.keysetSorts()
+	var err error
+
+	// 向前翻页时总有下一页；首页（无游标）没有上一页
+	if hasMore || backward {
+		if p.NextCursor, err = s.rowCursor(slice.Index(slice.Len()-1).Interface(), sorts, cursorNext); err != nil {
+			return err
+		}
+	}
+	if (backward && hasMore) || (!backward && p.Cursor != "") {
+		if p.PrevCursor, err = s.rowCursor(slice.Index(0).Interface(), sorts, cursorPrev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowCursor 根据一行记录的排序字段值生成游标
+func (s QuerySpec) rowCursor(row interface{}, sorts []SortField, direction string) (string, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+
+	values := make([]interface{}, len(sorts))
+	for i, sort := range sorts {
+		values[i] = fields[s.options.Sorts[sort.Name].JSONKey]
+	}
+
+	return encodeCursor(cursorPayload{
+		Spec:      s.specHash(sorts),
+		Direction: direction,
+		Values:    values,
+	})
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type cursorItem struct {
+	ID    uint    `json:"id" gorm:"primaryKey"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+var cursorItemOptions = QueryOptions{
+	SearchColumns: []string{"name"},
+	Ranges: map[string]RangeField{
+		"score": {Column: "score", Type: RangeNumber},
+		"id":    {Column: "id", Type: RangeNumber},
+	},
+	Sorts: map[string]Field{
+		"id":    {Column: "id", JSONKey: "id"},
+		"score": {Column: "score", JSONKey: "score"},
+	},
+	DefaultSort: []SortField{{Name: "id", Column: "id"}},
+	KeyField:    "id",
+}
+
+// setupCursorDB 创建 20 条记录，score 只有 0..3 四种取值，用于覆盖排序值相同的情况
+func setupCursorDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cursor.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := db.AutoMigrate(&cursorItem{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for i := 1; i <= 20; i++ {
+		item := cursorItem{ID: uint(i), Name: "item", Score: float64((i * 7) % 4)}
+		if err := db.Create(&item).Error; err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// fetchPage 按查询参数执行一次游标分页
+func fetchPage(t *testing.T, db *gorm.DB, query string) ([]cursorItem, Pagination, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?mode=cursor&"+query, nil)
+
+	pagination := GetPagination(c)
+	spec, err := ParseQuerySpec(c, cursorItemOptions)
+	if err != nil {
+		t.Fatalf("ParseQuerySpec: %v", err)
+	}
+	q, err := spec.ApplyKeyset(spec.ApplyFilters(db.Model(&cursorItem{})), &pagination)
+	if err != nil {
+		return nil, pagination, err
+	}
+	var items []cursorItem
+	if err := q.Find(&items).Error; err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if err := spec.FinishKeyset(&items, &pagination); err != nil {
+		t.Fatalf("FinishKeyset: %v", err)
+	}
+	return items, pagination, nil
+}
+
+func ids(items []cursorItem) []uint {
+	out := make([]uint, len(items))
+	for i, item := range items {
+		out[i] = item.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKeysetNextAndPrev(t *testing.T) {
+	db := setupCursorDB(t)
+
+	for _, sort := range []string{"score", "-score", "-score,-id"} {
+		t.Run(sort, func(t *testing.T) {
+			// 期望顺序：score 相同时按 id 排序，保证每条记录只出现一次
+			var want []cursorItem
+			order := strings.NewReplacer("-score", "score DESC", "-id", "id DESC").Replace(sort)
+			if !strings.Contains(order, "id") {
+				order += ",id"
+			}
+			if err := db.Order(order).Find(&want).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			var pages [][]uint
+			var cursors []Pagination
+			query := "sort=" + sort + "&pageSize=6"
+			for {
+				items, p, err := fetchPage(t, db, query)
+				if err != nil {
+					t.Fatalf("page %d: %v", len(pages)+1, err)
+				}
+				if len(pages) == 0 && p.PrevCursor != "" {
+					t.Error("first page has a prev cursor")
+				}
+				pages = append(pages, ids(items))
+				cursors = append(cursors, p)
+				if p.NextCursor == "" {
+					break
+				}
+				query = "sort=" + sort + "&pageSize=6&cursor=" + p.NextCursor
+			}
+
+			var got []uint
+			for _, page := range pages {
+				got = append(got, page...)
+			}
+			if !equalIDs(got, ids(want)) {
+				t.Fatalf("forward order = %v, want %v", got, ids(want))
+			}
+			if len(pages) != 4 {
+				t.Fatalf("pages = %d, want 4", len(pages))
+			}
+
+			// 从最后一页逐页向前，结果应与向后翻页时一致
+			for i := len(pages) - 1; i > 0; i-- {
+				items, p, err := fetchPage(t, db, "sort="+sort+"&pageSize=6&cursor="+cursors[i].PrevCursor)
+				if err != nil {
+					t.Fatalf("prev from page %d: %v", i+1, err)
+				}
+				if !equalIDs(ids(items), pages[i-1]) {
+					t.Errorf("prev from page %d = %v, want %v", i+1, ids(items), pages[i-1])
+				}
+				if p.NextCursor == "" {
+					t.Errorf("prev from page %d: missing next cursor", i+1)
+				}
+				if (i-1 == 0) != (p.PrevCursor == "") {
+					t.Errorf("prev from page %d: prev cursor = %q", i+1, p.PrevCursor)
+				}
+			}
+		})
+	}
+}
+
+func TestKeysetRejectsTamperedCursor(t *testing.T) {
+	db := setupCursorDB(t)
+
+	_, p, err := fetchPage(t, db, "sort=score&pageSize=5")
+	if err != nil || p.NextCursor == "" {
+		t.Fatalf("first page: %v, next = %q", err, p.NextCursor)
+	}
+	encoded, signature, _ := strings.Cut(p.NextCursor, ".")
+	data, _ := base64.RawURLEncoding.DecodeString(encoded)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(data), `"next"`, `"prev"`, 1))) + "." + signature
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"modified payload", forged},
+		{"modified signature", encoded + "." + base64.RawURLEncoding.EncodeToString([]byte("forged"))},
+		{"missing signature", encoded},
+		{"not base64", "!!!.???"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := fetchPage(t, db, "sort=score&pageSize=5&cursor="+tt.cursor)
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestKeysetCursorBoundToSortAndFilters(t *testing.T) {
+	db := setupCursorDB(t)
+
+	base := "sort=score&q=item&score_min=1&id_max=18&pageSize=3"
+	_, p, err := fetchPage(t, db, base)
+	if err != nil || p.NextCursor == "" {
+		t.Fatalf("first page: %v, next = %q", err, p.NextCursor)
+	}
+
+	// 同样的条件可以继续翻页，范围参数的先后顺序不影响
+	if _, _, err := fetchPage(t, db, "id_max=18&score_min=1&q=item&sort=score&pageSize=3&cursor="+p.NextCursor); err != nil {
+		t.Fatalf("same spec: %v", err)
+	}
+	// 页大小不属于查询条件，可以改变
+	if _, _, err := fetchPage(t, db, "sort=score&q=item&score_min=1&id_max=18&pageSize=5&cursor="+p.NextCursor); err != nil {
+		t.Fatalf("different page size: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"different sort", "sort=-score&q=item&score_min=1&id_max=18"},
+		{"extra sort field", "sort=score,-id&q=item&score_min=1&id_max=18"},
+		{"different search", "sort=score&q=other&score_min=1&id_max=18"},
+		{"different range", "sort=score&q=item&score_min=2&id_max=18"},
+		{"dropped range", "sort=score&q=item&score_min=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := fetchPage(t, db, tt.query+"&pageSize=3&cursor="+p.NextCursor)
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
@@ -6,11 +6,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 分页模式
+const (
+	PageModeOffset = "offset" // 默认，page/pageSize
+	PageModeCursor = "cursor" // 游标（keyset）分页
+)
+
+// maxPageSize 单页允许的最大记录数
+const maxPageSize = 100
+
 // Pagination 用于封装分页的参数
 type Pagination struct {
-	Page     int
-	PageSize int
-	Total    int64
+	Page       int
+	PageSize   int
+	Total      int64
+	Mode       string `json:",omitempty"`
+	Cursor     string `json:"-"`          // 请求中携带的游标
+	NextCursor string `json:",omitempty"` // 下一页游标，为空表示没有下一页
+	PrevCursor string `json:",omitempty"` // 上一页游标，为空表示没有上一页
+	direction  string
 }
 
 // DefaultPagination 设置分页的默认值
@@ -22,15 +36,36 @@ func DefaultPagination() Pagination {
 }
 
 // GetPagination 从请求中获取分页参数
+// 携带 cursor 参数或 mode=cursor 时使用游标分页，否则使用 page/pageSize
 func GetPagination(c *gin.Context) Pagination {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPagination().PageSize
+	}
+	// 超过上限时按上限返回，而不是退回默认值
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
 
 	// 返回分页结构体
-	return Pagination{
+	pagination := Pagination{
 		Page:     page,
 		PageSize: pageSize,
+		Cursor:   c.Query("cursor"),
+	}
+	if pagination.Cursor != "" || c.Query("mode") == PageModeCursor {
+		pagination.Mode = PageModeCursor
 	}
+	return pagination
+}
+
+// IsCursor 是否使用游标分页
+func (p *Pagination) IsCursor() bool {
+	return p.Mode == PageModeCursor
 }
 
 // Paginate 计算分页的偏移量
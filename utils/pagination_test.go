@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query    string
+		page     int
+		pageSize int
+		mode     string
+	}{
+		{"", 1, 10, ""},
+		{"page=3&pageSize=25", 3, 25, ""},
+		{"page=0&pageSize=0", 1, 10, ""},
+		{"page=-2&pageSize=-5", 1, 10, ""},
+		{"page=x&pageSize=y", 1, 10, ""},
+		{"pageSize=100", 1, 100, ""},
+		{"pageSize=101", 1, maxPageSize, ""},
+		{"pageSize=100000", 1, maxPageSize, ""},
+		{"mode=cursor&pageSize=20", 1, 20, PageModeCursor},
+		{"cursor=abc", 1, 10, PageModeCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+
+			p := GetPagination(c)
+			if p.Page != tt.page || p.PageSize != tt.pageSize || p.Mode != tt.mode {
+				t.Errorf("GetPagination(%q) = page %d, pageSize %d, mode %q; want %d, %d, %q",
+					tt.query, p.Page, p.PageSize, p.Mode, tt.page, tt.pageSize, tt.mode)
+			}
+		})
+	}
+}
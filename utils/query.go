@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"shared/apperr"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidQuery 查询参数不合法（未知字段、格式错误等）
+var ErrInvalidQuery = apperr.BadRequest("invalid_query", "invalid query")
+
+// 范围过滤支持的值类型
+const (
+	RangeNumber = "number"
+	RangeTime   = "time"
+)
+
+// Field 描述一个可以被排序、过滤或选择的字段
+type Field struct {
+	Column  string // 数据库列名
+	JSONKey string // 模型序列化为 JSON 时的键名，用于字段选择和生成游标
+	Type    string // 值类型，时间字段为 RangeTime，用于还原游标中的值
+}
+
+// RangeField 描述一个范围过滤字段，对应 <name>_min / <name>_max 查询参数
+type RangeField struct {
+	Column string
+	Type   string // RangeNumber 或 RangeTime
+}
+
+// QueryOptions 列表接口允许的查询条件白名单
+type QueryOptions struct {
+	SearchColumns []string              // q 参数搜索的列
+	FullText      bool                  // 是否使用 MySQL FULLTEXT 索引，否则回退为 LIKE
+	Ranges        map[string]RangeField // 范围过滤字段，键为参数名前缀
+	Sorts         map[string]Field      // sort 参数允许的字段
+	Fields        map[string]Field      // fields 参数允许的字段
+	DefaultSort   []SortField           // 未指定 sort 时的默认排序
+	KeyField      string                // 唯一键字段（须在 Sorts 中），游标分页时作为最后的排序条件
+}
+
+// SortField 排序字段
+type SortField struct {
+	Name   string // 参数中的字段名
+	Column string
+	Desc   bool
+}
+
+// RangeFilter 解析后的范围过滤条件
+type RangeFilter struct {
+	Column string
+	Min    interface{}
+	Max    interface{}
+}
+
+// QuerySpec 从请求中解析出的查询条件，可复用于任意列表接口
+type QuerySpec struct {
+	Search  string
+	Ranges  []RangeFilter
+	Sorts   []SortField
+	Fields  []string
+	options QueryOptions
+}
+
+// ParseQuerySpec 从请求中解析查询条件，参数格式：
+//
+//	q=keyword                      按 SearchColumns 搜索
+//	price_min=10&price_max=100     范围过滤，时间支持 RFC3339 或 2006-01-02
+//	sort=-price,name               多字段排序，"-" 表示降序
+//	fields=id,name                 只返回指定字段
+func ParseQuerySpec(c *gin.Context, opts QueryOptions) (QuerySpec, error) {
+	spec := QuerySpec{
+		Search:  strings.TrimSpace(c.Query("q")),
+		options: opts,
+	}
+
+	for name, field := range opts.Ranges {
+		filter := RangeFilter{Column: field.Column}
+		var err error
+		if filter.Min, err = parseRangeValue(c.Query(name+"_min"), field.Type); err != nil {
+			return spec, fmt.Errorf("%w: %s_min %v", ErrInvalidQuery, name, err)
+		}
+		if filter.Max, err = parseRangeValue(c.Query(name+"_max"), field.Type); err != nil {
+			return spec, fmt.Errorf("%w: %s_max %v", ErrInvalidQuery, name, err)
+		}
+		if filter.Min != nil || filter.Max != nil {
+			spec.Ranges = append(spec.Ranges, filter)
+		}
+	}
+
+	for _, name := range splitList(c.Query("sort")) {
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		field, ok := opts.Sorts[name]
+		if !ok {
+			return spec, fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, name)
+		}
+		spec.Sorts = append(spec.Sorts, SortField{Name: name, Column: field.Column, Desc: desc})
+	}
+	if len(spec.Sorts) == 0 {
+		spec.Sorts = append(spec.Sorts, opts.DefaultSort...)
+	}
+
+	for _, name := range splitList(c.Query("fields")) {
+		if _, ok := opts.Fields[name]; !ok {
+			return spec, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name)
+		}
+		spec.Fields = append(spec.Fields, name)
+	}
+
+	return spec, nil
+}
+
+// splitList 解析逗号分隔的参数，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRangeValue 按类型解析范围值，空字符串返回 nil
+func parseRangeValue(value, valueType string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	switch valueType {
+	case RangeTime:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, errors.New("must be RFC3339 or YYYY-MM-DD")
+		}
+		return t, nil
+	default:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return n, nil
+	}
+}
+
+// ApplyFilters 应用搜索和范围过滤条件，统计总数时也需要调用
+func (s QuerySpec) ApplyFilters(db *gorm.DB) *gorm.DB {
+	if s.Search != "" && len(s.options.SearchColumns) > 0 {
+		if s.options.FullText {
+			db = db.Where(fmt.Sprintf("MATCH(%s) AGAINST(? IN BOOLEAN MODE)",
+				strings.Join(s.options.SearchColumns, ", ")), s.Search)
+		} else {
+			pattern := "%" + escapeLike(s.Search) + "%"
+			conditions := make([]string, len(s.options.SearchColumns))
+			args := make([]interface{}, len(s.options.SearchColumns))
+			for i, column := range s.options.SearchColumns {
+				conditions[i] = column + " LIKE ? ESCAPE '!'"
+				args[i] = pattern
+			}
+			db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		}
+	}
+
+	for _, r := range s.Ranges {
+		if r.Min != nil {
+			db = db.Where(r.Column+" >= ?", r.Min)
+		}
+		if r.Max != nil {
+			db = db.Where(r.Column+" <= ?", r.Max)
+		}
+	}
+	return db
+}
+
+// ApplySort 应用排序条件
+func (s QuerySpec) ApplySort(db *gorm.DB) *gorm.DB {
+	for _, sort := range s.Sorts {
+		if sort.Desc {
+			db = db.Order(sort.Column + " DESC")
+		} else {
+			db = db.Order(sort.Column + " ASC")
+		}
+	}
+	return db
+}
+
+// ApplySelect 只查询选中的字段，未指定时查询全部
+// 排序字段和唯一键总会被查询，以便生成游标
+func (s QuerySpec) ApplySelect(db *gorm.DB) *gorm.DB {
+	if len(s.Fields) == 0 {
+		return db
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	add := func(column string) {
+		if column != "" && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	for _, name := range s.Fields {
+		add(s.options.Fields[name].Column)
+	}
+	for _, sort := range s.Sorts {
+		add(sort.Column)
+	}
+	add(s.options.Sorts[s.options.KeyField].Column)
+	return db.Select(columns)
+}
+
+// Project 将查询结果裁剪为选中的字段，未指定字段时原样返回
+func (s QuerySpec) Project(items interface{}) (interface{}, error) {
+	if len(s.Fields) == 0 {
+		return items, nil
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	projected := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		projected[i] = make(map[string]interface{}, len(s.Fields))
+		for _, name := range s.Fields {
+			projected[i][name] = row[s.options.Fields[name].JSONKey]
+		}
+	}
+	return projected, nil
+}
+
+// escapeLike 转义 LIKE 中的通配符，使用 "!" 作为转义符以兼容不同数据库
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
@@ -1,8 +0,0 @@
-package utils
-
-// 统一响应结构体
-type Response struct {
-	Code    int         `json:"code"`    // 状态码
-	Message string      `json:"message"` // 响应消息
-	Data    interface{} `json:"data"`    // 数据内容
-}
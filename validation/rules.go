@@ -0,0 +1,33 @@
+package validation
+
+import (
+	"go_core/config"
+	"go_core/models"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBytes 字符串的字节数不超过参数，max 按字符计数，bcrypt 等按字节限制的场景需要使用它
+func maxBytes(fl validator.FieldLevel) bool {
+	limit, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		return false
+	}
+	return len(fl.Field().String()) <= limit
+}
+
+// uniqueEmail 邮箱未被注册，与 services.CreateUser 的检查一致
+// 查询出错时视为通过，由 services.CreateUser 在写入前再次检查
+func uniqueEmail(fl validator.FieldLevel) bool {
+	email := fl.Field().String()
+	if email == "" || config.DB == nil {
+		return true
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return true
+	}
+	return count == 0
+}
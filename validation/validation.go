@@ -0,0 +1,15 @@
+// Package validation go_core 的自定义校验规则，校验引擎和中英文错误信息见 shared/validation
+// 客户端语言不受支持时错误信息使用英文
+package validation
+
+import sharedvalidation "shared/validation"
+
+var rules = []sharedvalidation.Rule{
+	{Tag: "unique_email", Func: uniqueEmail, En: "{0} is already registered", Zh: "{0}已被注册"},
+	{Tag: "max_bytes", Func: maxBytes, En: "{0} must be at most {1} bytes", Zh: "{0}最多为{1}个字节"},
+}
+
+// Setup 注册 go_core 的校验规则，需在处理请求前调用一次
+func Setup() error {
+	return sharedvalidation.Setup("en", rules...)
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"go_core/models"
+	"os"
+	sharedvalidation "shared/validation"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestMain(m *testing.M) {
+	if err := Setup(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestPasswordMaxBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"ascii at limit", strings.Repeat("a", 72), true},
+		{"ascii over limit", strings.Repeat("a", 73), false},
+		{"cjk at limit", strings.Repeat("密", 24), true}, // 72 字节
+		{"cjk over limit", strings.Repeat("密", 25), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := models.User{Name: "test", Email: "test@example.com", Password: tt.password}
+			err := binding.Validator.ValidateStruct(&user)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("ValidateStruct: %v", err)
+				}
+				return
+			}
+
+			details, ok := sharedvalidation.Translate(err, "en")
+			if !ok || len(details) != 1 || details[0].Field != "password" {
+				t.Fatalf("Translate = %v, %v; want one password error", details, ok)
+			}
+			if details[0].Message != "password must be at most 72 bytes" {
+				t.Fatalf("en message = %q", details[0].Message)
+			}
+			if zh, _ := sharedvalidation.Translate(err, "zh-CN"); zh[0].Message != "password最多为72个字节" {
+				t.Fatalf("zh message = %q", zh[0].Message)
+			}
+		})
+	}
+}